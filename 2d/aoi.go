@@ -1,24 +1,64 @@
 package two_dim
 
 import (
+	"time"
+
 	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/internal/maxtrack"
+	"github.com/beijian128/aoi/internal/players"
+	"github.com/beijian128/aoi/internal/sparse"
+	"github.com/beijian128/aoi/internal/view"
 )
 
 type Entity struct {
-	id  aoi.EntityID
-	pos *aoi.Position
+	id       aoi.EntityID
+	pos      *aoi.Position
+	rangeVal aoi.Float  // 视野半径 (XZ 平面上的圆)
+	facing   aoi.Facing // 朝向 (只使用 XZ 分量)，未设置时为全向视野
+	version  uint64     // 每次移动加一，用于判断视线检测的缓存是否失效
+
+	// emit 我属于哪些层，sense 我能感知哪些层
+	emit, sense aoi.LayerMask
+	stealth     aoi.Stealth
+	// body 包围半径，只用于射线检测
+	body aoi.Float
 
+	// grid 实体所在的格子 (或溢出桶)
+	grid *Grid
+
+	// los 视线检测缓存 (只保存处于视野范围内的目标)
+	los map[*Entity]losResult
+	// losBy 缓存了对我的视线检测结果的实体 (los 的反向索引)
+	losBy       aoi.Set[*Entity]
 	subscribers map[aoi.PlayerID]*aoi.Player
+
+	// visible 我当前能看见的实体
+	visible aoi.Set[*Entity]
+	// watchers 当前能看见我的实体 (visible 的反向索引)
+	watchers aoi.Set[*Entity]
 }
 
-func NewEntity(id aoi.EntityID, pos *aoi.Position) *Entity {
+func NewEntity(id aoi.EntityID, pos *aoi.Position, rangeVal aoi.Float) *Entity {
 	return &Entity{
 		id:          id,
 		pos:         pos,
+		rangeVal:    rangeVal,
+		emit:        aoi.LayerAll,
+		sense:       aoi.LayerAll,
 		subscribers: map[aoi.PlayerID]*aoi.Player{},
+		visible:     aoi.NewSet[*Entity](),
+		watchers:    aoi.NewSet[*Entity](),
+		los:         make(map[*Entity]losResult),
+		losBy:       aoi.NewSet[*Entity](),
 	}
 }
 
+// losResult 一次视线检测的结果，双方的 version 都没变时有效
+type losResult struct {
+	watcherVersion, targetVersion uint64
+	blocked                       bool
+}
+
 func (e *Entity) GetID() aoi.EntityID {
 	return e.id
 }
@@ -31,40 +71,83 @@ func (e *Entity) SetPos(pos *aoi.Position) {
 	e.pos = pos
 }
 
+func (e *Entity) GetRange() aoi.Float {
+	return e.rangeVal
+}
+
 // Grid 1个格子
 type Grid struct {
 	entities map[aoi.EntityID]*Entity // 格子中的所有实体
+	key      sparse.Key               // 稀疏格子的坐标 (只有 sparseIndex 使用)
 }
+
+func newGrid() *Grid {
+	return &Grid{entities: make(map[aoi.EntityID]*Entity)}
+}
+
+// OutOfBoundsPolicy 实体位置超出地图范围时的处理方式
+type OutOfBoundsPolicy int
+
+const (
+	OutOfBoundsClamp    OutOfBoundsPolicy = iota // 放进最近的边缘格子 (默认)
+	OutOfBoundsReject                            // 拒绝: AddEntity 不添加，MoveEntity 不移动
+	OutOfBoundsOverflow                          // 放进单独的溢出桶，所有越界的实体共用这一个桶
+)
+
 type Manager struct {
-	grids                  [][]*Grid
-	minX, minZ, maxX, maxZ int
-	gridSize               int
-	rowNum, columnNum      int
-	entities               map[aoi.EntityID]*Entity
-	players                map[aoi.PlayerID]*aoi.Player
+	// index 格子的组织方式: 固定范围 (NewManager) 或稀疏 (NewSparseManager)
+	index    spatialIndex
+	entities map[aoi.EntityID]*Entity
+	players  map[aoi.PlayerID]*aoi.Player
+
+	// maxRange 所有实体中最大的视野半径
+	// 实体移动时，只有这个距离内的实体才可能看见它
+	maxRange maxtrack.Tracker
+	// maxBody 所有实体中最大的包围半径 (射线检测时按它扩大扫描范围)
+	maxBody maxtrack.Tracker
+
+	// occluder 遮挡物，为 nil 时不做视线检测
+	occluder aoi.Occluder
+	// rule 可见性规则，为 nil 时使用 aoi.StealthRule
+	rule aoi.VisibilityRule
+	// leaveMargin 离开视野的余量：进入按视野半径判定，离开要超出视野半径 leaveMargin 才算
+	leaveMargin aoi.Float
+
+	// grace 延迟 Leave 的时钟与宽限期
+	grace view.Grace
 
 	cbk aoi.AOICallback
 }
 
 func (m *Manager) AddPlayer(id aoi.PlayerID) {
-	m.players[id] = &aoi.Player{
-		ID:        id,
-		FinalView: make(map[aoi.EntityID]int),
+	if _, ok := m.players[id]; ok { // 覆盖会让订阅关系指向旧的玩家对象
+		return
 	}
+	m.players[id] = players.New(id)
+}
+
+// RemovePlayer 移除玩家 (例如客户端断线)，取消它对所有实体的订阅
+// emitLeave 为 true 时对视野内的所有目标 (包括宽限期中的) 触发 OnLeave
+func (m *Manager) RemovePlayer(id aoi.PlayerID, emitLeave bool) {
+	players.Drop(m.players, m.entities, id, emitLeave, m.cbk, func(e *Entity) {
+		delete(e.subscribers, id)
+	})
 }
+
 func (m *Manager) AddEntity(id aoi.EntityID, pos *aoi.Position, rangeVal aoi.Float) {
 	if pos == nil {
 		return
 	}
-	entity := NewEntity(id, pos)
-	row, col := m.getGridIndexByPos(entity.GetPos())
-	grid := m.grids[row][col] // 一定能找到，这里就不判空了
-	grid.entities[entity.GetID()] = entity
+	if _, ok := m.entities[id]; ok { // 重复添加会破坏格子中的数据
+		return
+	}
+	entity := NewEntity(id, pos, rangeVal)
+	if !m.index.insert(entity) { // 越界且策略为 OutOfBoundsReject
+		return
+	}
 	m.entities[entity.GetID()] = entity
-	m.findSurroundEntities(entity).ForEach(func(other *Entity) bool {
-		m.onEnter(entity, other)
-		return false
-	})
+	m.maxRange.Add(rangeVal)
+	m.refresh(entity)
 }
 
 func (m *Manager) RemoveEntity(id aoi.EntityID) {
@@ -72,14 +155,31 @@ func (m *Manager) RemoveEntity(id aoi.EntityID) {
 	if entity == nil {
 		return
 	}
-	row, col := m.getGridIndexByPos(entity.GetPos())
-	grid := m.grids[row][col]
-	delete(grid.entities, id)
+	m.index.remove(entity)
 	delete(m.entities, id)
-	m.findSurroundEntities(entity).ForEach(func(other *Entity) bool {
-		m.onLeave(entity, other)
+	players.DropEntity(entity.subscribers, id)
+	// 按反向索引清掉双方的视线缓存
+	entity.losBy.ForEach(func(other *Entity) bool {
+		delete(other.los, entity)
 		return false
 	})
+	for other := range entity.los {
+		other.losBy.Remove(entity)
+	}
+	entity.visible.ForEach(func(other *Entity) bool {
+		m.leave(entity, other)
+		return false
+	})
+	entity.watchers.ForEach(func(other *Entity) bool {
+		m.leave(other, entity)
+		return false
+	})
+	if m.maxRange.Remove(entity.rangeVal) {
+		m.resetMaxRange()
+	}
+	if m.maxBody.Remove(entity.body) {
+		m.resetMaxBody()
+	}
 }
 
 func (m *Manager) MoveEntity(id aoi.EntityID, pos *aoi.Position) {
@@ -90,31 +190,111 @@ func (m *Manager) MoveEntity(id aoi.EntityID, pos *aoi.Position) {
 	if entity == nil {
 		return
 	}
+	if !m.index.move(entity, pos) { // 越界且策略为 OutOfBoundsReject，实体留在原地
+		return
+	}
+
+	// 即使没有跨格子，距离也可能发生了变化，需要重新判定
+	entity.SetPos(pos)
+	entity.version++
+	m.refresh(entity)
+}
 
-	oldRow, oldCol := m.getGridIndexByPos(entity.GetPos())
-	newRow, newCol := m.getGridIndexByPos(pos)
-	if oldRow == newRow && oldCol == newCol {
-		entity.SetPos(pos)
+// SetRange 修改视野半径，只会影响 e 作为观察者的视野
+func (m *Manager) SetRange(id aoi.EntityID, r aoi.Float) {
+	entity := m.entities[id]
+	if entity == nil {
 		return
 	}
+	old := entity.rangeVal
+	entity.rangeVal = r
+	// 先加入新值再移除旧值，只有唯一的最大视野实体缩小视野时才需要重新统计
+	m.maxRange.Add(r)
+	if m.maxRange.Remove(old) {
+		m.resetMaxRange()
+	}
+	m.index.rangeChanged(entity)
+	m.refreshView(entity)
+}
 
-	oldAOI := m.findSurroundEntities(entity)
+// SetFacing 设置朝向与视野张角，只会影响 e 作为观察者的视野
+func (m *Manager) SetFacing(id aoi.EntityID, facing *aoi.Position, fov aoi.Float) {
+	entity := m.entities[id]
+	if entity == nil {
+		return
+	}
+	if facing == nil {
+		entity.facing = aoi.Facing{}
+	} else {
+		entity.facing = aoi.Facing{Dir: aoi.Position{X: facing.X, Z: facing.Z}, FOV: fov}
+	}
+	m.refreshView(entity)
+}
 
-	delete(m.grids[oldRow][oldCol].entities, entity.GetID())
-	m.grids[newRow][newCol].entities[entity.GetID()] = entity
+// SetLayers 设置可见性层，e 作为观察者和被观察者的视野都会重新判定
+func (m *Manager) SetLayers(id aoi.EntityID, emit, sense aoi.LayerMask) {
+	entity := m.entities[id]
+	if entity == nil {
+		return
+	}
+	entity.emit, entity.sense = emit, sense
+	m.refresh(entity)
+}
 
-	entity.SetPos(pos)
-	newAOI := m.findSurroundEntities(entity)
+// SetStealth 设置潜行/侦测属性，e 作为观察者和被观察者的视野都会重新判定
+func (m *Manager) SetStealth(id aoi.EntityID, st aoi.Stealth) {
+	entity := m.entities[id]
+	if entity == nil {
+		return
+	}
+	entity.stealth = st
+	m.refresh(entity)
+}
+
+// SetVisibilityRule 设置可见性规则，并重新判定所有视野关系
+func (m *Manager) SetVisibilityRule(r aoi.VisibilityRule) {
+	m.rule = r
+	for _, e := range m.entities {
+		m.refreshView(e)
+	}
+}
+
+// SetLeaveMargin 设置离开视野的余量，避免目标在视野边界上来回走动时反复 Enter/Leave
+func (m *Manager) SetLeaveMargin(margin aoi.Float) {
+	if margin < 0 {
+		margin = 0
+	}
+	m.leaveMargin = margin
+	for _, e := range m.entities {
+		m.refreshView(e)
+	}
+}
 
-	leaveSet := oldAOI.Difference(newAOI)
-	enterSet := newAOI.Difference(oldAOI)
+// SetOccluder 设置遮挡物，并重新判定所有视野关系
+func (m *Manager) SetOccluder(o aoi.Occluder) {
+	m.occluder = o
+	for _, e := range m.entities {
+		clear(e.los)
+		e.losBy.Clear()
+	}
+	for _, e := range m.entities {
+		m.refreshView(e)
+	}
+}
 
-	leaveSet.ForEach(func(other *Entity) bool {
-		m.onLeave(entity, other)
+// refreshView 重新判定 e 作为观察者的视野 (视野半径或朝向变化时)
+func (m *Manager) refreshView(e *Entity) {
+	candidates := m.findEntitiesInRange(e.GetPos(), e.rangeVal)
+	e.visible.ForEach(func(other *Entity) bool {
+		candidates.Add(other)
 		return false
 	})
-	enterSet.ForEach(func(other *Entity) bool {
-		m.onEnter(entity, other)
+	// 缓存了视线检测结果的目标也要参与判定，跑出视野范围的会清掉缓存
+	for other := range e.los {
+		candidates.Add(other)
+	}
+	candidates.ForEach(func(other *Entity) bool {
+		m.updatePair(e, other)
 		return false
 	})
 }
@@ -130,6 +310,10 @@ func (m *Manager) GetView(id aoi.PlayerID) aoi.Set[aoi.EntityID] {
 			set.Add(eid)
 		}
 	}
+	// 宽限期内的目标仍然算在视野内
+	for eid := range player.PendingLeave {
+		set.Add(eid)
+	}
 	return set
 }
 
@@ -144,8 +328,9 @@ func (m *Manager) Subscribe(subscriberId aoi.PlayerID, targetId aoi.EntityID) {
 		return
 	}
 	target.subscribers[subscriberId] = subscriber
-	m.findSurroundEntities(target).ForEach(func(other *Entity) bool {
-		m.incrFinalView(subscriber, other)
+	subscriber.Subscriptions.Add(targetId)
+	target.visible.ForEach(func(other *Entity) bool {
+		view.Change(subscriber, other.id, 1, m.cbk, &m.grace)
 		return false
 	})
 }
@@ -160,8 +345,9 @@ func (m *Manager) Unsubscribe(subscriberId aoi.PlayerID, targetId aoi.EntityID)
 		return
 	}
 	delete(target.subscribers, subscriberId)
-	m.findSurroundEntities(target).ForEach(func(other *Entity) bool {
-		m.decrFinalView(subscriber, other)
+	subscriber.Subscriptions.Remove(targetId)
+	target.visible.ForEach(func(other *Entity) bool {
+		view.Change(subscriber, other.id, -1, m.cbk, &m.grace)
 		return false
 	})
 }
@@ -170,106 +356,191 @@ func (m *Manager) SetCallback(cb aoi.AOICallback) {
 	m.cbk = cb
 }
 
+// NewManager 创建固定范围的格子管理器，超出范围的实体按越界策略处理 (默认放进边缘的格子)
 func NewManager(gridSize, minX, minZ, maxX, maxZ int) *Manager {
-	m := &Manager{
-		minX:      minX,
-		minZ:      minZ,
-		maxX:      maxX,
-		maxZ:      maxZ,
-		gridSize:  gridSize,
-		entities:  make(map[aoi.EntityID]*Entity),
-		players:   make(map[aoi.PlayerID]*aoi.Player),
-		rowNum:    (maxX-minX)/gridSize + 1,
-		columnNum: (maxZ-minZ)/gridSize + 1,
-	}
-	m.grids = make([][]*Grid, m.rowNum)
-	for i := range m.grids {
-		m.grids[i] = make([]*Grid, m.columnNum)
-		for j := range m.grids[i] {
-			m.grids[i][j] = &Grid{
-				entities: make(map[aoi.EntityID]*Entity),
-			}
-		}
-	}
-	return m
+	return newManager(&flatIndex{cells: newDenseIndex(gridSize, minX, minZ, maxX, maxZ)})
 }
 
-func (m *Manager) getGridIndexByPos(pos *aoi.Position) (int, int) {
-	row := (int(pos.X) - m.minX) / m.gridSize
-	col := (int(pos.Z) - m.minZ) / m.gridSize
-	if row < 0 {
-		row = 0
+func newManager(index spatialIndex) *Manager {
+	return &Manager{
+		index:    index,
+		entities: make(map[aoi.EntityID]*Entity),
+		players:  make(map[aoi.PlayerID]*aoi.Player),
 	}
-	if col < 0 {
-		col = 0
+}
+
+// SetOutOfBoundsPolicy 设置越界处理方式，已有的实体按新的策略重新放进格子
+// 改为 OutOfBoundsReject 时已经越界的实体不会被移除，而是放进边缘的格子
+// 只对 NewManager 创建的管理器有效
+func (m *Manager) SetOutOfBoundsPolicy(p OutOfBoundsPolicy) {
+	if d := m.dense(); d != nil {
+		d.policy = p
+		d.rebucket(m.entities)
 	}
-	if row >= m.rowNum {
-		row = m.rowNum - 1
+}
+
+// Resize 运行时修改地图范围，已有的实体重新放进格子，视野关系不变
+// 新范围之外的实体按越界策略处理 (OutOfBoundsReject 时放进边缘的格子)
+// 只对 NewManager 创建的管理器有效
+func (m *Manager) Resize(minX, minZ, maxX, maxZ int) {
+	if d := m.dense(); d != nil {
+		d.initGrids(minX, minZ, maxX, maxZ)
+		d.rebucket(m.entities)
 	}
-	if col >= m.columnNum {
-		col = m.columnNum - 1
+}
+
+// dense NewManager 创建的固定范围格子，其它管理器返回 nil
+func (m *Manager) dense() *denseIndex {
+	if f, ok := m.index.(*flatIndex); ok {
+		d, _ := f.cells.(*denseIndex)
+		return d
 	}
-	return row, col
+	return nil
+}
+
+// findEntitiesInRange 找出以 pos 为中心、radius 为半径的正方形所覆盖的格子中的所有实体
+// 只是粗筛，调用方需要自己再做距离判定
+func (m *Manager) findEntitiesInRange(pos *aoi.Position, radius aoi.Float) aoi.Set[*Entity] {
+	set := aoi.NewSet[*Entity]()
+	m.index.forEachTarget(pos, radius, func(v *Entity) {
+		set.Add(v)
+	})
+	return set
 }
 
+// findSurroundEntities 找出所有可能与 e 存在视野关系的实体 (e 看见它们，或者它们看见 e)
 func (m *Manager) findSurroundEntities(e *Entity) aoi.Set[*Entity] {
-	pos := e.GetPos()
-	row, col := m.getGridIndexByPos(pos)
 	set := aoi.NewSet[*Entity]()
-	for i := row - 1; i <= row+1; i++ {
-		for j := col - 1; j <= col+1; j++ {
-			if i < 0 || i >= m.rowNum || j < 0 || j >= m.columnNum {
-				continue
-			}
-			for _, v := range m.grids[i][j].entities {
-				set.Add(v)
-			}
-		}
-	}
+	m.index.forEachNear(e.GetPos(), e.rangeVal, m.maxRange.Max(), 0, func(v *Entity) {
+		set.Add(v)
+	})
+	set.Remove(e)
 	return set
 }
 
-func (m *Manager) CanSee(watcherId aoi.PlayerID, targetId aoi.EntityID) bool {
-	watcher := m.players[watcherId]
-	if watcher == nil {
+// refresh 重新判定 e 与周围实体之间 (双向) 的可见性
+func (m *Manager) refresh(e *Entity) {
+	candidates := m.findSurroundEntities(e)
+	// 原本有视野关系但已经跑出扫描范围的实体，也要参与判定
+	e.visible.ForEach(func(other *Entity) bool {
+		candidates.Add(other)
+		return false
+	})
+	e.watchers.ForEach(func(other *Entity) bool {
+		candidates.Add(other)
 		return false
+	})
+	// 双方之间有视线缓存的实体也要参与判定，跑出视野范围的会清掉缓存
+	for other := range e.los {
+		candidates.Add(other)
 	}
-	return watcher.FinalView[targetId] > 0
+	e.losBy.ForEach(func(other *Entity) bool {
+		candidates.Add(other)
+		return false
+	})
+	candidates.ForEach(func(other *Entity) bool {
+		m.updatePair(e, other)
+		m.updatePair(other, e)
+		return false
+	})
 }
 
-func (m *Manager) incrFinalView(player *aoi.Player, e *Entity) {
-	player.FinalView[e.GetID()]++
-	if player.FinalView[e.GetID()] == 1 {
-		if m.cbk != nil {
-			m.cbk.OnEnter(player.ID, e.GetID())
-		}
+// updatePair 根据当前距离更新 watcher 对 target 的可见性，并在变化时通知订阅者
+func (m *Manager) updatePair(watcher, target *Entity) {
+	want := m.inRange(watcher, target)
+	has := watcher.visible.Contains(target)
+	if want && !has {
+		m.enter(watcher, target)
+	} else if !want && has {
+		m.leave(watcher, target)
 	}
 }
 
-func (m *Manager) decrFinalView(player *aoi.Player, e *Entity) {
-	player.FinalView[e.GetID()]--
-	if player.FinalView[e.GetID()] <= 0 {
-		if m.cbk != nil {
-			m.cbk.OnLeave(player.ID, e.GetID())
-		}
-		delete(player.FinalView, e.GetID())
+// inRange target 是否处于 watcher 的视野圆 (设置了朝向时为扇形) 内，且可见性层、可见性规则允许，视线没有被遮挡
+func (m *Manager) inRange(watcher, target *Entity) bool {
+	if watcher == target {
+		return false
 	}
+	dx := target.pos.X - watcher.pos.X
+	dz := target.pos.Z - watcher.pos.Z
+	r := watcher.rangeVal
+	if watcher.visible.Contains(target) {
+		r += m.leaveMargin
+	}
+	if dx*dx+dz*dz > r*r {
+		delete(watcher.los, target)
+		target.losBy.Remove(watcher)
+		return false
+	}
+	return watcher.sense&target.emit != 0 && watcher.facing.Contains(aoi.Position{X: dx, Z: dz}) && m.ruleAllows(watcher, target) && !m.blocked(watcher, target)
+}
+
+// ruleAllows 可见性规则是否允许 watcher 看见 target
+func (m *Manager) ruleAllows(watcher, target *Entity) bool {
+	rule := m.rule
+	if rule == nil {
+		rule = aoi.StealthRule{}
+	}
+	// 与其它距离判定一致，只使用 XZ 平面上的位置
+	return rule.Visible(
+		aoi.RuleSubject{ID: watcher.id, Pos: aoi.Position{X: watcher.pos.X, Z: watcher.pos.Z}, Stealth: watcher.stealth},
+		aoi.RuleSubject{ID: target.id, Pos: aoi.Position{X: target.pos.X, Z: target.pos.Z}, Stealth: target.stealth},
+	)
 }
 
-func (m *Manager) onEnter(e1, e2 *Entity) {
-	for _, subscriber := range e1.subscribers {
-		m.incrFinalView(subscriber, e2)
+// blocked watcher 到 target 的视线是否被遮挡，结果缓存到任意一方移动为止
+func (m *Manager) blocked(watcher, target *Entity) bool {
+	if m.occluder == nil {
+		return false
 	}
-	for _, subscriber := range e2.subscribers {
-		m.incrFinalView(subscriber, e1)
+	if r, ok := watcher.los[target]; ok && r.watcherVersion == watcher.version && r.targetVersion == target.version {
+		return r.blocked
 	}
+	b := m.occluder.Blocks(*watcher.pos, *target.pos)
+	watcher.los[target] = losResult{watcherVersion: watcher.version, targetVersion: target.version, blocked: b}
+	target.losBy.Add(watcher)
+	return b
 }
 
-func (m *Manager) onLeave(e1, e2 *Entity) {
-	for _, subscriber := range e1.subscribers {
-		m.decrFinalView(subscriber, e2)
+// resetMaxRange 重新统计最大视野半径
+func (m *Manager) resetMaxRange() {
+	m.maxRange.Reset()
+	for _, e := range m.entities {
+		m.maxRange.Add(e.rangeVal)
 	}
-	for _, subscriber := range e2.subscribers {
-		m.decrFinalView(subscriber, e1)
+}
+
+func (m *Manager) CanSee(watcherId aoi.PlayerID, targetId aoi.EntityID) bool {
+	watcher := m.players[watcherId]
+	if watcher == nil {
+		return false
+	}
+	if _, ok := watcher.PendingLeave[targetId]; ok {
+		return true
 	}
+	return watcher.FinalView[targetId] > 0
+}
+
+// SetLeaveGrace 设置延迟 Leave 的时钟与宽限期
+func (m *Manager) SetLeaveGrace(clock aoi.Clock, grace time.Duration) {
+	m.grace.Set(m.players, m.cbk, clock, grace)
+}
+
+// Tick 投递所有已经到期的 Leave
+func (m *Manager) Tick() {
+	m.grace.Tick(m.players, m.cbk)
+}
+
+// enter watcher 看见了 target
+func (m *Manager) enter(watcher, target *Entity) {
+	watcher.visible.Add(target)
+	target.watchers.Add(watcher)
+	view.Notify(watcher.subscribers, target.id, 1, m.cbk, &m.grace)
+}
+
+// leave watcher 看不见 target 了
+func (m *Manager) leave(watcher, target *Entity) {
+	watcher.visible.Remove(target)
+	target.watchers.Remove(watcher)
+	view.Notify(watcher.subscribers, target.id, -1, m.cbk, &m.grace)
 }
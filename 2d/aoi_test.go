@@ -22,9 +22,9 @@ func TestAOI(t *testing.T) {
 	pid := aoi.PlayerID(100)
 	wardId := aoi.EntityID(200)
 	mgr.AddPlayer(pid)
-	mgr.AddEntity(aoi.EntityID(pid), getRandPos(), 0)
+	mgr.AddEntity(aoi.EntityID(pid), getRandPos(), ViewRange)
 	mgr.Subscribe(pid, aoi.EntityID(pid))
-	mgr.AddEntity(wardId, getRandPos(), 0)
+	mgr.AddEntity(wardId, getRandPos(), ViewRange)
 	mgr.Subscribe(pid, wardId)
 	go simulationLoop()
 	fs := http.FileServer(http.Dir("./static"))
@@ -42,9 +42,10 @@ func TestAOI(t *testing.T) {
 }
 
 const (
-	MapSize  = 600
-	GridSize = 50
-	Port     = ":8080"
+	MapSize   = 600
+	GridSize  = 50
+	ViewRange = 80
+	Port      = ":8080"
 )
 
 var upgrader = websocket.Upgrader{
@@ -191,7 +192,7 @@ func buildSnapshot() Snapshot {
 		})
 
 		if id == 100 || id == 200 {
-			rawAoiSet := mgr.findSurroundEntities(e)
+			rawAoiSet := e.visible
 			aoiSet := mgr.GetView(aoi.PlayerID(id))
 			aoiSet.ForEach(func(targetID aoi.EntityID) bool {
 				target := mgr.entities[targetID]
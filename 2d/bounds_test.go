@@ -0,0 +1,70 @@
+package two_dim
+
+import (
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestOutOfBounds(t *testing.T) {
+	// 向下取整: 负坐标 -0.5 与 0.5 分属两个格子
+	m := NewManager(10, -100, -100, 100, 100)
+	m.AddEntity(1, &aoi.Position{X: -0.5, Z: 0}, 0)
+	m.AddEntity(2, &aoi.Position{X: 0.5, Z: 0}, 0)
+	if m.entities[1].grid == m.entities[2].grid {
+		t.Fatal("-0.5 and 0.5 must land in different cells")
+	}
+
+	// 拒绝: 越界的实体不添加，越界的移动不生效
+	m = NewManager(10, 0, 0, 100, 100)
+	m.SetOutOfBoundsPolicy(OutOfBoundsReject)
+	m.AddEntity(1, &aoi.Position{X: 150, Z: 50}, 10)
+	if _, ok := m.entities[1]; ok {
+		t.Fatal("Reject should not add an out-of-bounds entity")
+	}
+	m.AddEntity(1, &aoi.Position{X: 50, Z: 50}, 10)
+	m.MoveEntity(1, &aoi.Position{X: 50, Z: -1})
+	if m.entities[1].pos.Z != 50 {
+		t.Fatal("Reject should ignore an out-of-bounds move")
+	}
+
+	// 溢出桶: 越界的实体不会堆在边缘格子里，但仍然能互相看见
+	m = NewManager(10, 0, 0, 100, 100)
+	cb := &countingCallback{}
+	m.SetCallback(cb)
+	m.SetOutOfBoundsPolicy(OutOfBoundsOverflow)
+	m.AddPlayer(1)
+	m.AddPlayer(2)
+	m.AddEntity(1, &aoi.Position{X: 500, Z: 500}, 10)
+	m.Subscribe(1, 1)
+	m.AddEntity(2, &aoi.Position{X: 95, Z: 95}, 10)
+	m.Subscribe(2, 2)
+	m.AddEntity(3, &aoi.Position{X: 505, Z: 500}, 0)
+	m.AddEntity(4, &aoi.Position{X: 102, Z: 95}, 0)
+	d := m.dense()
+	if len(d.overflow.entities) != 3 || len(d.grids[d.rowNum-1][d.columnNum-1].entities) != 0 {
+		t.Fatal("out-of-bounds entities should go to the overflow bucket")
+	}
+	if !m.CanSee(1, 3) || !m.CanSee(2, 4) {
+		t.Fatal("entities in the overflow bucket should still be visible")
+	}
+	m.MoveEntity(4, &aoi.Position{X: 95, Z: 99})
+	if !m.CanSee(2, 4) || len(d.overflow.entities) != 2 {
+		t.Fatal("moving back in bounds should leave the overflow bucket")
+	}
+
+	// 运行时扩大地图，视野关系不变，也不产生事件
+	enter, leave := cb.enter, cb.leave
+	m.Resize(0, 0, 1000, 1000)
+	if len(d.overflow.entities) != 0 || m.entities[1].grid != d.grids[50][50] {
+		t.Fatal("Resize should move entities into the new cells")
+	}
+	if !m.CanSee(1, 3) || !m.CanSee(2, 4) || cb.enter != enter || cb.leave != leave {
+		t.Fatal("Resize must not change visibility")
+	}
+	m.MoveEntity(3, &aoi.Position{X: 520, Z: 500})
+	m.MoveEntity(3, &aoi.Position{X: 508, Z: 500})
+	if !m.CanSee(1, 3) || cb.enter != enter+1 || cb.leave != leave+1 {
+		t.Fatal("visibility should keep working after Resize")
+	}
+}
@@ -0,0 +1,50 @@
+package two_dim
+
+import (
+	"testing"
+
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/aoitest"
+)
+
+func TestConformance(t *testing.T) {
+	aoitest.RunConformance(t, func() aoi.AOIManager {
+		return NewManager(10, 0, 0, 1000, 1000)
+	})
+}
+
+func TestDifferential(t *testing.T) {
+	aoitest.RunDifferential(t, func() aoi.AOIManager {
+		return NewManager(3, 490, 490, 520, 520)
+	}, func() aoi.AOIManager {
+		return aoitest.NewOracle(aoitest.InCircleXZ)
+	}, 200, 300)
+}
+
+func TestSparseConformance(t *testing.T) {
+	aoitest.RunConformance(t, func() aoi.AOIManager {
+		return NewSparseManager(10)
+	})
+}
+
+func TestSparseDifferential(t *testing.T) {
+	aoitest.RunDifferential(t, func() aoi.AOIManager {
+		return NewSparseManager(3)
+	}, func() aoi.AOIManager {
+		return aoitest.NewOracle(aoitest.InCircleXZ)
+	}, 200, 300)
+}
+
+func TestHierarchicalConformance(t *testing.T) {
+	aoitest.RunConformance(t, func() aoi.AOIManager {
+		return NewHierarchicalManager(4)
+	})
+}
+
+func TestHierarchicalDifferential(t *testing.T) {
+	aoitest.RunDifferential(t, func() aoi.AOIManager {
+		return NewHierarchicalManager(1)
+	}, func() aoi.AOIManager {
+		return aoitest.NewOracle(aoitest.InCircleXZ)
+	}, 200, 300)
+}
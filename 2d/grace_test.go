@@ -0,0 +1,55 @@
+package two_dim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestLeaveGrace(t *testing.T) {
+	m := NewManager(10, 0, 0, 100, 100)
+	cb := &countingCallback{}
+	m.SetCallback(cb)
+	clock := aoi.NewManualClock(time.Unix(0, 0))
+	m.SetLeaveGrace(clock, 3*time.Second)
+	m.AddPlayer(1)
+	m.AddEntity(1, &aoi.Position{X: 50, Z: 50}, 20)
+	m.Subscribe(1, 1)
+	m.AddEntity(2, &aoi.Position{X: 60, Z: 50}, 0)
+
+	// 短暂离开又回来：没有 Leave，也没有第二次 Enter
+	m.MoveEntity(2, &aoi.Position{X: 80, Z: 50})
+	if !m.CanSee(1, 2) || !m.GetView(1).Contains(2) {
+		t.Fatal("target should stay visible during the grace period")
+	}
+	clock.Advance(2 * time.Second)
+	m.Tick()
+	m.MoveEntity(2, &aoi.Position{X: 60, Z: 50})
+	clock.Advance(5 * time.Second)
+	m.Tick()
+	if cb.enter != 1 || cb.leave != 0 {
+		t.Fatalf("a brief gap should be smoothed out, enter=%d leave=%d", cb.enter, cb.leave)
+	}
+
+	// 超出宽限期
+	m.MoveEntity(2, &aoi.Position{X: 80, Z: 50})
+	clock.Advance(2 * time.Second)
+	m.Tick()
+	if cb.leave != 0 {
+		t.Fatal("leave should be held back until the grace period expires")
+	}
+	clock.Advance(time.Second)
+	m.Tick()
+	if m.CanSee(1, 2) || cb.leave != 1 {
+		t.Fatalf("expired leave should be delivered, leave=%d", cb.leave)
+	}
+
+	// 关闭宽限期时立即投递
+	m.MoveEntity(2, &aoi.Position{X: 60, Z: 50})
+	m.MoveEntity(2, &aoi.Position{X: 80, Z: 50})
+	m.SetLeaveGrace(nil, 0)
+	if m.CanSee(1, 2) || cb.leave != 2 {
+		t.Fatalf("disabling the grace period should flush pending leaves, leave=%d", cb.leave)
+	}
+}
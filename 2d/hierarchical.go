@@ -0,0 +1,222 @@
+package two_dim
+
+import (
+	"github.com/beijian128/aoi"
+)
+
+// hierMaxLevels 分层网格的最大层数，视野半径超过最高一层格子大小的实体都放在最高一层
+const hierMaxLevels = 40
+
+// hierLevel 分层网格中的一层
+type hierLevel struct {
+	size aoi.Float
+	// targets 所有实体都按位置放进每一个现有的层 (查找我能看见的实体时，按我的视野半径挑一层来扫描)
+	targets *sparseIndex
+	// watchers 只放视野半径匹配这一层的实体 (查找能看见我的实体时，每一层只需要扫描周围一圈格子)
+	watchers *sparseIndex
+}
+
+// hierEntry 实体在各层中所在的格子
+type hierEntry struct {
+	level   int     // 作为观察者所在的层
+	targets []*Grid // 每一层中按位置所在的格子 (不存在的层为 nil)
+	watch   *Grid   // 第 level 层中作为观察者所在的格子
+}
+
+// hierIndex 分层网格，第 k 层的格子大小为 baseSize*2^k
+// 层在第一个观察者进入时创建，最后一个观察者离开时删除，实体只需要放进有观察者的那几层
+type hierIndex struct {
+	baseSize aoi.Float
+	levels   []*hierLevel // 没有观察者的层为 nil
+	entries  map[*Entity]*hierEntry
+}
+
+// NewHierarchicalManager 创建分层网格管理器 (没有地图范围)
+// 实体按视野半径放到格子不小于视野半径的最低一层，500 米的 Boss 与 5 米的小动物各自使用合适的格子大小；
+// 接口、订阅语义与回调行为与 NewManager 相同
+func NewHierarchicalManager(baseSize int) *Manager {
+	return newManager(&hierIndex{
+		baseSize: aoi.Float(baseSize),
+		entries:  make(map[*Entity]*hierEntry),
+	})
+}
+
+// levelOf 视野半径为 r 的实体所在的层
+func (h *hierIndex) levelOf(r aoi.Float) int {
+	k, size := 0, h.baseSize
+	for size < r && k < hierMaxLevels-1 {
+		size *= 2
+		k++
+	}
+	return k
+}
+
+// ensureLevel 第 k 层不存在时创建，已有的实体按位置放进新的层
+func (h *hierIndex) ensureLevel(k int) {
+	for len(h.levels) <= k {
+		h.levels = append(h.levels, nil)
+	}
+	if h.levels[k] != nil {
+		return
+	}
+	size := h.baseSize
+	for range k {
+		size *= 2
+	}
+	lv := &hierLevel{size: size, targets: newSparseIndex(size), watchers: newSparseIndex(size)}
+	h.levels[k] = lv
+	for e, entry := range h.entries {
+		for len(entry.targets) <= k {
+			entry.targets = append(entry.targets, nil)
+		}
+		entry.targets[k] = moveToCell(lv.targets, nil, e, e.pos)
+	}
+}
+
+// releaseLevel 第 k 层没有观察者之后删除这一层，并去掉末尾不存在的层
+func (h *hierIndex) releaseLevel(k int) {
+	if h.levels[k].watchers.cells.Len() > 0 {
+		return
+	}
+	h.levels[k] = nil
+	for _, entry := range h.entries {
+		if k < len(entry.targets) {
+			entry.targets[k] = nil
+		}
+	}
+	for len(h.levels) > 0 && h.levels[len(h.levels)-1] == nil {
+		h.levels = h.levels[:len(h.levels)-1]
+	}
+}
+
+// levelFor 扫描半径为 r 时使用的层：格子不小于 r 的最低一层，没有时使用最高一层
+func (h *hierIndex) levelFor(r aoi.Float) *hierLevel {
+	for k := h.levelOf(r); k < len(h.levels); k++ {
+		if h.levels[k] != nil {
+			return h.levels[k]
+		}
+	}
+	if len(h.levels) == 0 { // 没有任何实体
+		return nil
+	}
+	return h.levels[len(h.levels)-1]
+}
+
+func (h *hierIndex) insert(e *Entity) bool {
+	entry := &hierEntry{level: h.levelOf(e.rangeVal)}
+	h.ensureLevel(entry.level)
+	entry.targets = make([]*Grid, len(h.levels))
+	for k, lv := range h.levels {
+		if lv != nil {
+			entry.targets[k] = moveToCell(lv.targets, nil, e, e.pos)
+		}
+	}
+	entry.watch = moveToCell(h.levels[entry.level].watchers, nil, e, e.pos)
+	h.entries[e] = entry
+	return true
+}
+
+func (h *hierIndex) move(e *Entity, pos *aoi.Position) bool {
+	entry := h.entries[e]
+	for k, lv := range h.levels {
+		if lv != nil {
+			entry.targets[k] = moveToCell(lv.targets, entry.targets[k], e, pos)
+		}
+	}
+	entry.watch = moveToCell(h.levels[entry.level].watchers, entry.watch, e, pos)
+	return true
+}
+
+func (h *hierIndex) remove(e *Entity) {
+	entry := h.entries[e]
+	for k, lv := range h.levels {
+		if lv != nil {
+			leaveCell(lv.targets, entry.targets[k], e)
+		}
+	}
+	leaveCell(h.levels[entry.level].watchers, entry.watch, e)
+	delete(h.entries, e)
+	h.releaseLevel(entry.level)
+}
+
+// rangeChanged 视野半径变化之后可能需要换层
+func (h *hierIndex) rangeChanged(e *Entity) {
+	entry := h.entries[e]
+	level := h.levelOf(e.rangeVal)
+	if level == entry.level {
+		return
+	}
+	h.ensureLevel(level)
+	old := entry.level
+	leaveCell(h.levels[old].watchers, entry.watch, e)
+	entry.level = level
+	entry.watch = moveToCell(h.levels[level].watchers, nil, e, e.pos)
+	h.releaseLevel(old)
+}
+
+// forEachTarget 在格子不小于 radius 的那一层上扫描，最多 3x3 个格子
+func (h *hierIndex) forEachTarget(pos *aoi.Position, radius aoi.Float, fn func(e *Entity)) {
+	lv := h.levelFor(radius)
+	if lv == nil {
+		return
+	}
+	lv.targets.forEachCell(pos, radius, func(g *Grid) {
+		for _, e := range g.entities {
+			fn(e)
+		}
+	})
+}
+
+// forEachNear 我能看见的实体按我的视野半径查找；
+// 能看见我的实体逐层查找，第 k 层的观察者视野半径不超过该层的格子大小，只需要扫描周围一圈格子
+func (h *hierIndex) forEachNear(pos *aoi.Position, radius, maxRange, margin aoi.Float, fn func(e *Entity)) {
+	h.forEachTarget(pos, radius+margin, fn)
+	for k, lv := range h.levels {
+		if lv == nil {
+			continue
+		}
+		r := lv.size
+		if k == len(h.levels)-1 { // 最高一层可能放着视野半径超过格子大小的实体
+			r = max(r, maxRange)
+		}
+		lv.watchers.forEachCell(pos, r+margin, func(g *Grid) {
+			for _, e := range g.entities {
+				fn(e)
+			}
+		})
+	}
+}
+
+// accepts 没有地图范围，任何位置都可以
+func (h *hierIndex) accepts(*aoi.Position) bool {
+	return true
+}
+
+// forEachAlongRay 在格子不小于 body 的那一层上沿射线遍历
+func (h *hierIndex) forEachAlongRay(ray aoi.Ray, maxDist, body aoi.Float, limit int, fn func(e *Entity)) bool {
+	lv := h.levelFor(body)
+	if lv == nil {
+		return true
+	}
+	return alongRay(lv.targets, ray, maxDist, body, limit, fn)
+}
+
+// moveToCell 把 e 从 old (为 nil 时表示还不在 store 中) 挪到 store 中 pos 所在的格子，返回新的格子
+func moveToCell(store *sparseIndex, old *Grid, e *Entity, pos *aoi.Position) *Grid {
+	g := store.cellOf(pos)
+	if g != old {
+		if old != nil {
+			leaveCell(store, old, e)
+		}
+		g.entities[e.id] = e
+	}
+	return g
+}
+
+// leaveCell 把 e 从 store 的格子 g 中移出，格子空了就释放
+func leaveCell(store *sparseIndex, g *Grid, e *Entity) {
+	delete(g.entities, e.id)
+	if len(g.entities) == 0 {
+		store.release(g)
+	}
+}
@@ -0,0 +1,115 @@
+package two_dim
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestHierarchicalLevels(t *testing.T) {
+	const n = 60
+	rnd := rand.New(rand.NewSource(1))
+	m := NewHierarchicalManager(5)
+	h := m.index.(*hierIndex)
+	pos := make(map[aoi.EntityID]aoi.Position)
+	ranges := make(map[aoi.EntityID]aoi.Float)
+	randPos := func() aoi.Position {
+		return aoi.Position{X: aoi.Float(rnd.Intn(2000) - 1000), Z: aoi.Float(rnd.Intn(2000) - 1000)}
+	}
+	// 一个 Boss、几个中等视野的实体，其余都是小动物
+	rangeOf := func(i aoi.EntityID) aoi.Float {
+		switch {
+		case i == 1:
+			return 500
+		case i <= 5:
+			return aoi.Float(20 + rnd.Intn(40))
+		}
+		return aoi.Float(rnd.Intn(6))
+	}
+	for i := aoi.EntityID(1); i <= n; i++ {
+		pos[i], ranges[i] = randPos(), rangeOf(i)
+		p := pos[i]
+		m.AddPlayer(aoi.PlayerID(i))
+		m.AddEntity(i, &p, ranges[i])
+		m.Subscribe(aoi.PlayerID(i), i)
+	}
+	if h.entries[m.entities[1]].level != 7 || h.entries[m.entities[n]].level > 1 {
+		t.Fatal("entities should be placed on the level matching their range")
+	}
+	for step := 0; step < 3000; step++ {
+		id := aoi.EntityID(rnd.Intn(n) + 1)
+		if rnd.Intn(10) == 0 {
+			ranges[id] = rangeOf(aoi.EntityID(rnd.Intn(n) + 1))
+			m.SetRange(id, ranges[id])
+			continue
+		}
+		// 小步移动为主，偶尔瞬移
+		p := pos[id]
+		if rnd.Intn(20) == 0 {
+			p = randPos()
+		} else {
+			p.X += aoi.Float(rnd.Intn(21) - 10)
+			p.Z += aoi.Float(rnd.Intn(21) - 10)
+		}
+		pos[id] = p
+		m.MoveEntity(id, &p)
+	}
+	for w := aoi.EntityID(1); w <= n; w++ {
+		if h.entries[m.entities[w]].level != h.levelOf(ranges[w]) {
+			t.Fatalf("entity %d is on the wrong level", w)
+		}
+		for tgt := aoi.EntityID(1); tgt <= n; tgt++ {
+			dx, dz := pos[tgt].X-pos[w].X, pos[tgt].Z-pos[w].Z
+			want := w != tgt && dx*dx+dz*dz <= ranges[w]*ranges[w]
+			if got := m.CanSee(aoi.PlayerID(w), tgt); got != want {
+				t.Fatalf("CanSee(%d, %d) = %v, want %v", w, tgt, got, want)
+			}
+		}
+	}
+}
+
+func TestHierarchicalLevelRelease(t *testing.T) {
+	m := NewHierarchicalManager(5)
+	h := m.index.(*hierIndex)
+	live := func() (ks []int) {
+		for k, lv := range h.levels {
+			if lv != nil {
+				ks = append(ks, k)
+			}
+		}
+		return ks
+	}
+	m.AddPlayer(1)
+	m.AddEntity(1, &aoi.Position{}, 500)
+	m.AddEntity(2, &aoi.Position{X: 100}, 1)
+	m.Subscribe(1, 1)
+	if got := live(); !slices.Equal(got, []int{0, 7}) {
+		t.Fatalf("levels = %v, want [0 7]", got)
+	}
+	if !m.CanSee(1, 2) {
+		t.Fatal("the boss should see the small entity")
+	}
+
+	m.SetRange(1, 50)
+	if got := live(); !slices.Equal(got, []int{0, 4}) || len(h.levels) != 5 {
+		t.Fatalf("levels = %v (len %d), want [0 4]", got, len(h.levels))
+	}
+	if m.CanSee(1, 2) {
+		t.Fatal("shrinking the range should leave")
+	}
+	m.MoveEntity(2, &aoi.Position{X: 40})
+	if !m.CanSee(1, 2) {
+		t.Fatal("moving into the range should enter")
+	}
+
+	m.RemoveEntity(2)
+	if got := live(); !slices.Equal(got, []int{4}) {
+		t.Fatalf("levels = %v, want [4]", got)
+	}
+	m.RemoveEntity(1)
+	if len(h.levels) != 0 || m.QueryRadius(aoi.Position{}, 10) != nil || m.Raycast(aoi.Position{}, aoi.Position{X: 1}, 100) != nil {
+		t.Fatalf("an empty index should have no levels, got %d", len(h.levels))
+	}
+}
@@ -0,0 +1,41 @@
+package two_dim
+
+import (
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestLeaveMargin(t *testing.T) {
+	m := NewManager(10, 0, 0, 100, 100)
+	cb := &countingCallback{}
+	m.SetCallback(cb)
+	m.SetLeaveMargin(3)
+	m.AddPlayer(1)
+	m.AddEntity(1, &aoi.Position{X: 50, Z: 50}, 20)
+	m.Subscribe(1, 1)
+	m.AddEntity(2, &aoi.Position{X: 71, Z: 50}, 0)
+	if m.CanSee(1, 2) {
+		t.Fatal("entering still uses the plain range")
+	}
+	// 在边界上来回走动只触发一次 Enter
+	for i := 0; i < 10; i++ {
+		m.MoveEntity(2, &aoi.Position{X: 69, Z: 50})
+		m.MoveEntity(2, &aoi.Position{X: 72, Z: 50})
+	}
+	if !m.CanSee(1, 2) || cb.enter != 1 || cb.leave != 0 {
+		t.Fatalf("walking along the edge should not flap, enter=%d leave=%d", cb.enter, cb.leave)
+	}
+	m.MoveEntity(2, &aoi.Position{X: 74, Z: 50})
+	if m.CanSee(1, 2) || cb.leave != 1 {
+		t.Fatal("leaving beyond range+margin should leave")
+	}
+
+	// 缩小余量会让处于余量带中的目标离开
+	m.MoveEntity(2, &aoi.Position{X: 69, Z: 50})
+	m.MoveEntity(2, &aoi.Position{X: 72, Z: 50})
+	m.SetLeaveMargin(0)
+	if m.CanSee(1, 2) {
+		t.Fatal("removing the margin should drop targets outside the range")
+	}
+}
@@ -0,0 +1,217 @@
+package two_dim
+
+import (
+	"math"
+
+	"github.com/beijian128/aoi"
+)
+
+// spatialIndex 实体的空间索引，Manager 只通过它来找候选实体
+type spatialIndex interface {
+	// insert 把 e 按 e.pos 放进索引，返回 false 表示拒绝 (越界策略为 OutOfBoundsReject)
+	insert(e *Entity) bool
+	// move 把 e 挪到 pos 处 (调用方随后会更新 e.pos)，返回 false 表示拒绝，e 留在原地
+	move(e *Entity, pos *aoi.Position) bool
+	remove(e *Entity)
+	// rangeChanged e 的视野半径变化之后调用
+	rangeChanged(e *Entity)
+	// forEachTarget 遍历以 pos 为中心、radius 为半径的正方形内可能存在的实体 (只是粗筛)
+	forEachTarget(pos *aoi.Position, radius aoi.Float, fn func(e *Entity))
+	// forEachNear 遍历可能与 pos 处、视野半径为 radius 的实体存在 (双向) 视野关系的实体 (只是粗筛)
+	// maxRange 是所有实体中最大的视野半径，margin 是离开余量
+	forEachNear(pos *aoi.Position, radius, maxRange, margin aoi.Float, fn func(e *Entity))
+	// accepts 严格模式下是否接受 pos
+	accepts(pos *aoi.Position) bool
+	// forEachAlongRay 沿射线逐格遍历距离射线不超过 body 的格子中的实体 (只是粗筛，同一个实体可能出现多次)
+	// 需要走过的格子超过 limit 个时不遍历，返回 false
+	forEachAlongRay(ray aoi.Ray, maxDist, body aoi.Float, limit int, fn func(e *Entity)) bool
+}
+
+// cellStore 单层格子的组织方式
+type cellStore interface {
+	// cellOf pos 应该放进的格子，返回 nil 表示拒绝 (越界策略为 OutOfBoundsReject)
+	cellOf(pos *aoi.Position) *Grid
+	// release 格子中的最后一个实体离开之后调用
+	release(g *Grid)
+	// forEachCell 遍历以 pos 为中心、radius 为半径的正方形所覆盖的格子
+	forEachCell(pos *aoi.Position, radius aoi.Float, fn func(g *Grid))
+	accepts(pos *aoi.Position) bool
+	// layout 格子大小与格线的起点 (格线在 ox+k*size、oz+k*size 处)
+	layout() (size, ox, oz aoi.Float)
+}
+
+// flatIndex 单层格子，所有实体共用一种格子大小
+// 能看见 e 的实体一定在 maxRange 之内，按 maxRange 扫描
+type flatIndex struct {
+	cells cellStore
+}
+
+func (f *flatIndex) insert(e *Entity) bool {
+	g := f.cells.cellOf(e.pos)
+	if g == nil {
+		return false
+	}
+	g.entities[e.id] = e
+	e.grid = g
+	return true
+}
+
+func (f *flatIndex) move(e *Entity, pos *aoi.Position) bool {
+	g := f.cells.cellOf(pos)
+	if g == nil {
+		return false
+	}
+	if g != e.grid {
+		f.remove(e)
+		g.entities[e.id] = e
+		e.grid = g
+	}
+	return true
+}
+
+// remove 把 e 从所在的格子中移出，格子空了之后回收
+func (f *flatIndex) remove(e *Entity) {
+	delete(e.grid.entities, e.id)
+	if len(e.grid.entities) == 0 {
+		f.cells.release(e.grid)
+	}
+	e.grid = nil
+}
+
+func (f *flatIndex) rangeChanged(*Entity) {}
+
+func (f *flatIndex) forEachTarget(pos *aoi.Position, radius aoi.Float, fn func(e *Entity)) {
+	f.cells.forEachCell(pos, radius, func(g *Grid) {
+		for _, e := range g.entities {
+			fn(e)
+		}
+	})
+}
+
+func (f *flatIndex) forEachNear(pos *aoi.Position, radius, maxRange, margin aoi.Float, fn func(e *Entity)) {
+	f.forEachTarget(pos, max(radius, maxRange)+margin, fn)
+}
+
+func (f *flatIndex) accepts(pos *aoi.Position) bool {
+	return f.cells.accepts(pos)
+}
+
+func (f *flatIndex) forEachAlongRay(ray aoi.Ray, maxDist, body aoi.Float, limit int, fn func(e *Entity)) bool {
+	return alongRay(f.cells, ray, maxDist, body, limit, fn)
+}
+
+// denseIndex 固定范围的格子，创建时一次性分配 rowNum*columnNum 个格子
+type denseIndex struct {
+	grids                  [][]*Grid
+	minX, minZ, maxX, maxZ int
+	gridSize               int
+	rowNum, columnNum      int
+
+	// policy 越界处理方式，overflow 是 OutOfBoundsOverflow 时的溢出桶
+	policy   OutOfBoundsPolicy
+	overflow *Grid
+}
+
+func newDenseIndex(gridSize, minX, minZ, maxX, maxZ int) *denseIndex {
+	d := &denseIndex{
+		gridSize: gridSize,
+		overflow: newGrid(),
+	}
+	d.initGrids(minX, minZ, maxX, maxZ)
+	return d
+}
+
+func (d *denseIndex) initGrids(minX, minZ, maxX, maxZ int) {
+	d.minX, d.minZ, d.maxX, d.maxZ = minX, minZ, maxX, maxZ
+	d.rowNum = (maxX-minX)/d.gridSize + 1
+	d.columnNum = (maxZ-minZ)/d.gridSize + 1
+	d.grids = make([][]*Grid, d.rowNum)
+	for i := range d.grids {
+		d.grids[i] = make([]*Grid, d.columnNum)
+		for j := range d.grids[i] {
+			d.grids[i][j] = newGrid()
+		}
+	}
+}
+
+// rebucket 把所有实体重新放进格子，按策略会被拒绝的实体放进边缘的格子
+func (d *denseIndex) rebucket(entities map[aoi.EntityID]*Entity) {
+	for _, row := range d.grids {
+		for _, g := range row {
+			clear(g.entities)
+		}
+	}
+	clear(d.overflow.entities)
+	for id, e := range entities {
+		g := d.cellOf(e.pos)
+		if g == nil {
+			row, col := d.getGridIndexByPos(e.pos)
+			g = d.grids[row][col]
+		}
+		g.entities[id] = e
+		e.grid = g
+	}
+}
+
+// inBounds pos 是否落在地图范围内 (NaN 算越界)
+func (d *denseIndex) inBounds(pos *aoi.Position) bool {
+	return pos.X >= aoi.Float(d.minX) && pos.X <= aoi.Float(d.maxX) &&
+		pos.Z >= aoi.Float(d.minZ) && pos.Z <= aoi.Float(d.maxZ)
+}
+
+func (d *denseIndex) accepts(pos *aoi.Position) bool {
+	return d.policy == OutOfBoundsOverflow || d.inBounds(pos)
+}
+
+// cellOf 越界时按策略返回边缘格子、溢出桶或 nil (拒绝)
+func (d *denseIndex) cellOf(pos *aoi.Position) *Grid {
+	if !d.inBounds(pos) {
+		switch d.policy {
+		case OutOfBoundsReject:
+			return nil
+		case OutOfBoundsOverflow:
+			return d.overflow
+		}
+	}
+	row, col := d.getGridIndexByPos(pos)
+	return d.grids[row][col]
+}
+
+// release 格子是预先分配的，不需要回收
+func (d *denseIndex) release(*Grid) {}
+
+func (d *denseIndex) layout() (aoi.Float, aoi.Float, aoi.Float) {
+	return aoi.Float(d.gridSize), aoi.Float(d.minX), aoi.Float(d.minZ)
+}
+
+func (d *denseIndex) forEachCell(pos *aoi.Position, radius aoi.Float, fn func(g *Grid)) {
+	// 扫描范围超出地图时，溢出桶中的实体也可能在范围内
+	if len(d.overflow.entities) > 0 &&
+		(!d.inBounds(&aoi.Position{X: pos.X - radius, Z: pos.Z - radius}) || !d.inBounds(&aoi.Position{X: pos.X + radius, Z: pos.Z + radius})) {
+		fn(d.overflow)
+	}
+	minRow, minCol := d.getGridIndexByPos(&aoi.Position{X: pos.X - radius, Z: pos.Z - radius})
+	maxRow, maxCol := d.getGridIndexByPos(&aoi.Position{X: pos.X + radius, Z: pos.Z + radius})
+	for i := minRow; i <= maxRow; i++ {
+		for j := minCol; j <= maxCol; j++ {
+			fn(d.grids[i][j])
+		}
+	}
+}
+
+// getGridIndexByPos pos 所在格子的下标，超出范围的夹到边缘
+func (d *denseIndex) getGridIndexByPos(pos *aoi.Position) (int, int) {
+	return cellIndex(pos.X, d.minX, d.gridSize, d.rowNum), cellIndex(pos.Z, d.minZ, d.gridSize, d.columnNum)
+}
+
+// cellIndex 坐标 v 在该轴上的格子下标，向下取整 (-0.5 与 0.5 不在同一个格子)，结果夹在 [0, n) 内
+func cellIndex(v aoi.Float, lo, size, n int) int {
+	i := math.Floor((float64(v) - float64(lo)) / float64(size))
+	if !(i >= 0) { // 包括 NaN
+		return 0
+	}
+	if i >= float64(n) {
+		return n - 1
+	}
+	return int(i)
+}
@@ -0,0 +1,44 @@
+package two_dim
+
+import (
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestLayers(t *testing.T) {
+	const (
+		layerLiving aoi.LayerMask = 1 << iota
+		layerGhost
+	)
+	m := NewManager(10, 0, 0, 100, 100)
+	cb := &countingCallback{}
+	m.SetCallback(cb)
+	m.AddPlayer(1)
+	m.AddEntity(1, &aoi.Position{X: 50, Z: 50}, 20)
+	m.Subscribe(1, 1)
+	m.SetLayers(1, layerLiving, layerLiving)
+	m.AddEntity(2, &aoi.Position{X: 55, Z: 50}, 20)
+	m.SetLayers(2, layerGhost, aoi.LayerAll)
+	m.AddEntity(3, &aoi.Position{X: 45, Z: 50}, 20) // 默认所有层
+	if m.CanSee(1, 2) || !m.CanSee(1, 3) {
+		t.Fatal("living player should not see the ghost")
+	}
+
+	// 玩家死亡，能感知幽灵
+	*cb = countingCallback{}
+	m.SetLayers(1, layerLiving, layerLiving|layerGhost)
+	if !m.CanSee(1, 2) || cb.enter != 1 {
+		t.Fatalf("dead player should see the ghost, enter=%d", cb.enter)
+	}
+
+	// GM 隐身
+	m.SetLayers(3, 0, aoi.LayerAll)
+	if m.CanSee(1, 3) || cb.leave != 1 {
+		t.Fatalf("invisible GM should leave, leave=%d", cb.leave)
+	}
+	m.MoveEntity(3, &aoi.Position{X: 50, Z: 55})
+	if m.CanSee(1, 3) {
+		t.Fatal("invisible GM should stay invisible while moving")
+	}
+}
@@ -0,0 +1,80 @@
+package two_dim
+
+import (
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+type countingCallback struct {
+	enter, leave int
+}
+
+func (c *countingCallback) OnEnter(aoi.PlayerID, aoi.EntityID) { c.enter++ }
+func (c *countingCallback) OnLeave(aoi.PlayerID, aoi.EntityID) { c.leave++ }
+
+func TestRangeFiltering(t *testing.T) {
+	m := NewManager(10, 0, 0, 100, 100)
+	cb := &countingCallback{}
+	m.SetCallback(cb)
+	m.AddPlayer(1)
+	m.AddEntity(1, &aoi.Position{X: 50, Z: 50}, 25)
+	m.Subscribe(1, 1)
+
+	// 距离 20，处于半径 25 内，跨越了多个格子
+	m.AddEntity(2, &aoi.Position{X: 70, Z: 50}, 0)
+	if !m.CanSee(1, 2) {
+		t.Fatal("entity within range should be visible")
+	}
+	// 对角线距离约 28.3，同在 3x3 格子内但超出半径
+	m.AddEntity(3, &aoi.Position{X: 70, Z: 70}, 0)
+	if m.CanSee(1, 3) {
+		t.Fatal("entity out of range should not be visible")
+	}
+	if m.CanSee(1, 1) {
+		t.Fatal("entity should not see itself")
+	}
+
+	// 同一个格子内移动也要重新判定距离
+	m.MoveEntity(2, &aoi.Position{X: 76, Z: 50})
+	if m.CanSee(1, 2) || cb.leave != 1 {
+		t.Fatalf("moving out of range inside one cell should leave, leave=%d", cb.leave)
+	}
+	m.MoveEntity(3, &aoi.Position{X: 65, Z: 65})
+	if !m.CanSee(1, 3) || cb.enter != 2 {
+		t.Fatalf("moving into range should enter, enter=%d", cb.enter)
+	}
+
+	// 视野是单向的: 2 的半径为 0，看不见 1
+	m.AddPlayer(2)
+	m.Subscribe(2, 2)
+	if m.CanSee(2, 1) {
+		t.Fatal("zero-range entity should not see others")
+	}
+}
+
+// 被遮挡的目标瞬移到远处再移除，双方都不能残留视线缓存
+// 只有有观察者的层才存在，最后一个观察者离开时删除这一层
+func TestMaxRange(t *testing.T) {
+	m := NewManager(10, 0, 0, 100, 100)
+	for i := aoi.EntityID(1); i <= 5; i++ {
+		m.AddEntity(i, &aoi.Position{X: aoi.Float(i * 10), Z: 50}, 20)
+	}
+	m.AddEntity(6, &aoi.Position{X: 50, Z: 10}, 5)
+	m.RemoveEntity(1)
+	if m.maxRange.Max() != 20 {
+		t.Fatalf("maxRange = %v with four more entities at the max, want 20", m.maxRange.Max())
+	}
+	m.SetRange(2, 10)
+	m.SetRange(6, 30)
+	m.SetRange(6, 5)
+	if m.maxRange.Max() != 20 {
+		t.Fatalf("maxRange = %v after SetRange, want 20", m.maxRange.Max())
+	}
+	for i := aoi.EntityID(2); i <= 5; i++ {
+		m.RemoveEntity(i)
+	}
+	if m.maxRange.Max() != 5 {
+		t.Fatalf("maxRange = %v after removing every widest entity, want 5", m.maxRange.Max())
+	}
+}
@@ -0,0 +1,81 @@
+package two_dim
+
+import (
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+type countingOccluder struct {
+	aoi.Occluder
+	calls int
+}
+
+func (c *countingOccluder) Blocks(from, to aoi.Position) bool {
+	c.calls++
+	return c.Occluder.Blocks(from, to)
+}
+
+func TestOcclusion(t *testing.T) {
+	m := NewManager(10, 0, 0, 100, 100)
+	m.AddPlayer(1)
+	m.AddEntity(1, &aoi.Position{X: 40, Z: 50}, 30)
+	m.Subscribe(1, 1)
+	m.AddEntity(2, &aoi.Position{X: 60, Z: 50}, 30)
+	if !m.CanSee(1, 2) {
+		t.Fatal("no wall yet, entity 2 should be visible")
+	}
+
+	occ := &countingOccluder{Occluder: aoi.NewObstacleLayer(aoi.Segment2D{A: aoi.Position{X: 50, Z: 45}, B: aoi.Position{X: 50, Z: 55}})}
+	m.SetOccluder(occ)
+	if m.CanSee(1, 2) {
+		t.Fatal("wall between the entities should block the view")
+	}
+
+	// 没有移动时复用缓存
+	calls := occ.calls
+	m.SetRange(1, 31)
+	if occ.calls != calls {
+		t.Fatalf("line of sight should be cached until an endpoint moves, %d new tests", occ.calls-calls)
+	}
+
+	// 绕过墙的一端
+	m.MoveEntity(2, &aoi.Position{X: 60, Z: 65})
+	if !m.CanSee(1, 2) || occ.calls == calls {
+		t.Fatal("moving around the wall should re-test and enter")
+	}
+
+	m.SetOccluder(nil)
+	m.MoveEntity(2, &aoi.Position{X: 60, Z: 50})
+	if !m.CanSee(1, 2) {
+		t.Fatal("removing the occluder should restore the view")
+	}
+}
+
+func TestOcclusionCacheRelease(t *testing.T) {
+	m := NewSparseManager(10)
+	m.SetOccluder(aoi.NewObstacleLayer(aoi.Segment2D{A: aoi.Position{X: 50, Z: 45}, B: aoi.Position{X: 50, Z: 55}}))
+	m.AddEntity(1, &aoi.Position{X: 40, Z: 50}, 30)
+	m.AddEntity(2, &aoi.Position{X: 60, Z: 50}, 0) // 只有 1 看 2，瞬移时要靠反向索引找到 1
+	w, target := m.entities[1], m.entities[2]
+	if len(w.los) != 1 || target.losBy.Size() != 1 {
+		t.Fatalf("the blocked pair should be cached, got %d and %d", len(w.los), target.losBy.Size())
+	}
+
+	m.MoveEntity(2, &aoi.Position{X: 1e6, Z: 1e6})
+	if len(w.los) != 0 || target.losBy.Size() != 0 {
+		t.Fatalf("teleport should drop the cache, got %d and %d", len(w.los), target.losBy.Size())
+	}
+	m.MoveEntity(2, &aoi.Position{X: 60, Z: 50})
+	m.RemoveEntity(2)
+	if len(w.los) != 0 || w.losBy.Size() != 0 {
+		t.Fatalf("removal should drop the cache, got %d and %d", len(w.los), w.losBy.Size())
+	}
+
+	// 缩小视野之后不再保留视野之外的缓存
+	m.AddEntity(3, &aoi.Position{X: 60, Z: 50}, 0)
+	m.SetRange(1, 5)
+	if len(w.los) != 0 {
+		t.Fatalf("shrinking the range should drop the cache, got %d", len(w.los))
+	}
+}
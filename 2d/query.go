@@ -0,0 +1,87 @@
+package two_dim
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/beijian128/aoi"
+)
+
+var _ aoi.SpatialQuery = (*Manager)(nil)
+
+// QueryRadius XZ 平面上与 center 的距离不超过 r 的所有实体
+func (m *Manager) QueryRadius(center aoi.Position, r aoi.Float) []aoi.EntityID {
+	var ids []aoi.EntityID
+	m.index.forEachTarget(&center, r, func(e *Entity) {
+		if distSq(&center, e.pos) <= r*r {
+			ids = append(ids, e.id)
+		}
+	})
+	slices.Sort(ids)
+	return ids
+}
+
+// QueryBox XZ 平面上落在 box 中的所有实体 (忽略 Y)
+func (m *Manager) QueryBox(box aoi.Box) []aoi.EntityID {
+	minX, maxX := min(box.Min.X, box.Max.X), max(box.Min.X, box.Max.X)
+	minZ, maxZ := min(box.Min.Z, box.Max.Z), max(box.Min.Z, box.Max.Z)
+	center := aoi.Position{X: (minX + maxX) / 2, Z: (minZ + maxZ) / 2}
+	var ids []aoi.EntityID
+	m.index.forEachTarget(&center, max(maxX-minX, maxZ-minZ)/2, func(e *Entity) {
+		if e.pos.X >= minX && e.pos.X <= maxX && e.pos.Z >= minZ && e.pos.Z <= maxZ {
+			ids = append(ids, e.id)
+		}
+	})
+	slices.Sort(ids)
+	return ids
+}
+
+// nearestMaxSteps QueryNearest 最多倍增扫描半径的次数，之后直接检查所有位置有效的实体
+// (位置为 NaN/Inf 的实体永远不会落在扫描半径内，有这样的实体时倍增凑不够 k 个)
+const nearestMaxSteps = 64
+
+// QueryNearest XZ 平面上离 center 最近的 k 个实体
+// 扫描半径从 1 开始倍增，直到半径内的实体足够 k 个 (半径外的实体一定更远)
+func (m *Manager) QueryNearest(center aoi.Position, k int) []aoi.EntityID {
+	// 中心不是有限的坐标时，所有距离都是 NaN 或无穷大，倍增半径永远凑不够 k 个
+	if k <= 0 || !center.X.IsFinite() || !center.Z.IsFinite() {
+		return nil
+	}
+	var found []*Entity
+	for step, r := 0, aoi.Float(1); ; step, r = step+1, r*2 {
+		found = found[:0]
+		if step == nearestMaxSteps {
+			for _, e := range m.entities {
+				if e.pos.X.IsFinite() && e.pos.Z.IsFinite() {
+					found = append(found, e)
+				}
+			}
+			break
+		}
+		m.index.forEachTarget(&center, r, func(e *Entity) {
+			if distSq(&center, e.pos) <= r*r {
+				found = append(found, e)
+			}
+		})
+		if len(found) >= k || len(found) == len(m.entities) {
+			break
+		}
+	}
+	slices.SortFunc(found, func(a, b *Entity) int {
+		if c := cmp.Compare(distSq(&center, a.pos), distSq(&center, b.pos)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.id, b.id)
+	})
+	ids := make([]aoi.EntityID, 0, min(k, len(found)))
+	for _, e := range found[:min(k, len(found))] {
+		ids = append(ids, e.id)
+	}
+	return ids
+}
+
+// distSq XZ 平面上距离的平方
+func distSq(a, b *aoi.Position) aoi.Float {
+	dx, dz := a.X-b.X, a.Z-b.Z
+	return dx*dx + dz*dz
+}
@@ -0,0 +1,97 @@
+package two_dim
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestSpatialQuery(t *testing.T) {
+	factories := map[string]func() *Manager{
+		"grid":         func() *Manager { return NewManager(10, -200, -200, 200, 200) },
+		"sparse":       func() *Manager { return NewSparseManager(10) },
+		"hierarchical": func() *Manager { return NewHierarchicalManager(5) },
+	}
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(1))
+			m := factory()
+			cb := &countingCallback{}
+			m.SetCallback(cb)
+			pos := make(map[aoi.EntityID]aoi.Position)
+			for i := aoi.EntityID(1); i <= 200; i++ {
+				p := aoi.Position{X: aoi.Float(rnd.Intn(400) - 200), Y: aoi.Float(rnd.Intn(100)), Z: aoi.Float(rnd.Intn(400) - 200)}
+				pos[i] = p
+				m.AddEntity(i, &p, aoi.Float(rnd.Intn(30)))
+			}
+			for q := 0; q < 100; q++ {
+				c := aoi.Position{X: aoi.Float(rnd.Intn(400) - 200), Z: aoi.Float(rnd.Intn(400) - 200)}
+				r := aoi.Float(rnd.Intn(80))
+				var want []aoi.EntityID
+				for id := aoi.EntityID(1); id <= 200; id++ {
+					if distSq(&c, &aoi.Position{X: pos[id].X, Z: pos[id].Z}) <= r*r {
+						want = append(want, id)
+					}
+				}
+				if got := m.QueryRadius(c, r); !slices.Equal(got, want) {
+					t.Fatalf("QueryRadius(%v, %v) = %v, want %v", c, r, got, want)
+				}
+
+				box := aoi.Box{Min: c, Max: aoi.Position{X: c.X + aoi.Float(rnd.Intn(121)-60), Z: c.Z + aoi.Float(rnd.Intn(121)-60)}}
+				want = want[:0]
+				for id := aoi.EntityID(1); id <= 200; id++ {
+					p := pos[id]
+					if p.X >= min(box.Min.X, box.Max.X) && p.X <= max(box.Min.X, box.Max.X) && p.Z >= min(box.Min.Z, box.Max.Z) && p.Z <= max(box.Min.Z, box.Max.Z) {
+						want = append(want, id)
+					}
+				}
+				if got := m.QueryBox(box); !slices.Equal(got, want) {
+					t.Fatalf("QueryBox(%v) = %v, want %v", box, got, want)
+				}
+
+				k := rnd.Intn(10) + 1
+				got := m.QueryNearest(c, k)
+				if len(got) != k {
+					t.Fatalf("QueryNearest returned %d entities, want %d", len(got), k)
+				}
+				// 结果按距离排序，且没有被漏掉的更近的实体
+				farthest := distSq(&c, &aoi.Position{X: pos[got[k-1]].X, Z: pos[got[k-1]].Z})
+				closer := 0
+				for id := aoi.EntityID(1); id <= 200; id++ {
+					if distSq(&c, &aoi.Position{X: pos[id].X, Z: pos[id].Z}) < farthest {
+						closer++
+					}
+				}
+				for i := 1; i < k; i++ {
+					p0, p1 := pos[got[i-1]], pos[got[i]]
+					if distSq(&c, &p0) > distSq(&c, &p1) {
+						t.Fatalf("QueryNearest result is not sorted: %v", got)
+					}
+				}
+				if closer >= k {
+					t.Fatalf("QueryNearest(%v, %d) = %v missed closer entities", c, k, got)
+				}
+			}
+			if got := m.QueryNearest(aoi.Position{}, 500); len(got) != 200 {
+				t.Fatalf("QueryNearest with k > n returned %d entities", len(got))
+			}
+			for _, c := range []aoi.Position{{X: aoi.Float(math.NaN())}, {Z: aoi.FloatInf(-1)}} {
+				if got := m.QueryNearest(c, 3); got != nil {
+					t.Fatalf("QueryNearest(%v) = %v, want nil", c, got)
+				}
+			}
+			// 位置为 NaN 的实体永远凑不进扫描半径，倍增有上限；极远处的实体仍然能找到
+			m.AddEntity(201, &aoi.Position{X: aoi.Float(math.NaN())}, 0)
+			m.AddEntity(202, &aoi.Position{X: 1e30}, 0)
+			if got := m.QueryNearest(aoi.Position{}, 500); len(got) != 201 || got[200] != 202 {
+				t.Fatalf("QueryNearest should skip the NaN entity and keep the far one, got %d entities", len(got))
+			}
+			if len(m.players) != 0 || cb.enter != 0 || cb.leave != 0 {
+				t.Fatal("queries must not create players or fire callbacks")
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package two_dim
+
+import (
+	"math"
+
+	"github.com/beijian128/aoi"
+)
+
+var _ aoi.Raycaster = (*Manager)(nil)
+
+// SetBodyRadius 设置包围半径 (XZ 平面上的圆)，只用于射线检测
+func (m *Manager) SetBodyRadius(id aoi.EntityID, r aoi.Float) {
+	entity := m.entities[id]
+	if entity == nil {
+		return
+	}
+	old := entity.body
+	entity.body = max(r, 0)
+	m.maxBody.Add(entity.body)
+	if m.maxBody.Remove(old) {
+		m.resetMaxBody()
+	}
+}
+
+// Raycast XZ 平面上的射线检测 (忽略 Y)
+// 按 DDA 沿射线逐格扫描；射线要走过的格子比实体还多时 (例如 maxDist 为无穷大)，直接检查所有实体
+func (m *Manager) Raycast(origin, dir aoi.Position, maxDist aoi.Float) []aoi.RaycastHit {
+	origin.Y, dir.Y = 0, 0
+	ray, ok := aoi.NewRay(origin, dir)
+	if !ok || !(maxDist >= 0) {
+		return nil
+	}
+	var hits []aoi.RaycastHit
+	test := func(e *Entity) {
+		if t, ok := ray.Hit(aoi.Position{X: e.pos.X, Z: e.pos.Z}, e.body); ok && t <= maxDist {
+			hits = append(hits, aoi.RaycastHit{ID: e.id, Dist: t})
+		}
+	}
+	seen := aoi.NewSet[*Entity]()
+	walked := m.index.forEachAlongRay(ray, maxDist, m.maxBody.Max(), len(m.entities), func(e *Entity) {
+		if !seen.Contains(e) {
+			seen.Add(e)
+			test(e)
+		}
+	})
+	if !walked {
+		for _, e := range m.entities {
+			test(e)
+		}
+	}
+	aoi.SortHits(hits)
+	return hits
+}
+
+// resetMaxBody 重新统计最大包围半径
+func (m *Manager) resetMaxBody() {
+	m.maxBody.Reset()
+	for _, e := range m.entities {
+		m.maxBody.Add(e.body)
+	}
+}
+
+// alongRay 按 DDA 依次走过射线 [0, maxDist] 经过的每个格子，扫描该格子向外扩大 body 的范围
+// (包围半径不超过 body 的实体只要被射线命中，它的中心一定在某个扩大之后的格子里)
+func alongRay(cells cellStore, ray aoi.Ray, maxDist, body aoi.Float, limit int, fn func(e *Entity)) bool {
+	size, ox, oz := cells.layout()
+	s := float64(size)
+	dx, dz := float64(ray.Dir.X), float64(ray.Dir.Z)
+	// 经过的格子数不超过两个轴上跨过的格线数之和加一 (maxDist 为无穷大或 NaN 时也不遍历)
+	if !((math.Abs(dx)+math.Abs(dz))*float64(maxDist)/s+2 <= float64(limit)) {
+		return false
+	}
+	px, pz := float64(ray.Origin.X-ox), float64(ray.Origin.Z-oz)
+	cx, cz := math.Floor(px/s), math.Floor(pz/s)
+	stepX, tMaxX, tDeltaX := ddaAxis(px, dx, cx, s)
+	stepZ, tMaxZ, tDeltaZ := ddaAxis(pz, dz, cz, s)
+	for {
+		center := aoi.Position{X: ox + aoi.Float((cx+0.5)*s), Z: oz + aoi.Float((cz+0.5)*s)}
+		cells.forEachCell(&center, size/2+body, func(g *Grid) {
+			for _, e := range g.entities {
+				fn(e)
+			}
+		})
+		if tMaxX < tMaxZ {
+			if tMaxX > float64(maxDist) {
+				break
+			}
+			cx += stepX
+			tMaxX += tDeltaX
+		} else {
+			if tMaxZ > float64(maxDist) {
+				break
+			}
+			cz += stepZ
+			tMaxZ += tDeltaZ
+		}
+	}
+	return true
+}
+
+// ddaAxis 射线在一个轴上的步进方向、到第一条格线的距离与相邻格线之间的距离 (p 为起点坐标，c 为起点所在格子)
+func ddaAxis(p, d, c, size float64) (step, tMax, tDelta float64) {
+	switch {
+	case d > 0:
+		return 1, ((c+1)*size - p) / d, size / d
+	case d < 0:
+		return -1, (c*size - p) / d, -size / d
+	}
+	return 0, math.Inf(1), math.Inf(1)
+}
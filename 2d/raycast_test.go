@@ -0,0 +1,88 @@
+package two_dim
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestRaycast(t *testing.T) {
+	factories := map[string]func() *Manager{
+		"grid": func() *Manager {
+			m := NewManager(10, -100, -100, 100, 100)
+			m.SetOutOfBoundsPolicy(OutOfBoundsOverflow)
+			return m
+		},
+		"sparse":       func() *Manager { return NewSparseManager(10) },
+		"hierarchical": func() *Manager { return NewHierarchicalManager(5) },
+	}
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+			m := factory()
+			// 沿 X 轴的射线依次穿过半径为 2 的 3、半径为 0 的 1，2 在射线起点后面，4 偏离射线太远
+			m.AddEntity(1, &aoi.Position{X: 30, Y: 50}, 10)
+			m.AddEntity(2, &aoi.Position{X: -5}, 10)
+			m.AddEntity(3, &aoi.Position{X: 10, Z: 1}, 10)
+			m.AddEntity(4, &aoi.Position{X: 20, Z: 3}, 10)
+			m.SetBodyRadius(3, 2)
+			m.SetBodyRadius(4, 2)
+			got := m.Raycast(aoi.Position{}, aoi.Position{X: 2}, 50)
+			want := []aoi.RaycastHit{{ID: 3, Dist: 10 - aoi.Float(math.Sqrt(3))}, {ID: 1, Dist: 30}}
+			if !slices.Equal(got, want) {
+				t.Fatalf("Raycast = %v, want %v", got, want)
+			}
+			if got := m.Raycast(aoi.Position{}, aoi.Position{X: 1}, 20); len(got) != 1 || got[0].ID != 3 {
+				t.Fatalf("Raycast shorter than the distance to 1 = %v", got)
+			}
+			if got := m.Raycast(aoi.Position{}, aoi.Position{}, 50); got != nil {
+				t.Fatalf("Raycast with zero direction = %v", got)
+			}
+			for id := aoi.EntityID(1); id <= 4; id++ {
+				m.RemoveEntity(id)
+			}
+
+			// 与暴力解比较，包括越界的实体与无限长的射线
+			rnd := rand.New(rand.NewSource(1))
+			pos := make(map[aoi.EntityID]aoi.Position)
+			body := make(map[aoi.EntityID]aoi.Float)
+			for i := aoi.EntityID(1); i <= 300; i++ {
+				p := aoi.Position{X: aoi.Float(rnd.Intn(240) - 120), Z: aoi.Float(rnd.Intn(240) - 120)}
+				pos[i] = p
+				m.AddEntity(i, &p, aoi.Float(rnd.Intn(30)))
+				if rnd.Intn(2) == 0 {
+					body[i] = aoi.Float(rnd.Intn(15))
+					m.SetBodyRadius(i, body[i])
+				}
+			}
+			for i := aoi.EntityID(1); i <= 30; i++ {
+				m.RemoveEntity(i)
+				delete(pos, i)
+			}
+			for q := 0; q < 200; q++ {
+				origin := aoi.Position{X: aoi.Float(rnd.Intn(240) - 120), Z: aoi.Float(rnd.Intn(240) - 120)}
+				dir := aoi.Position{X: aoi.Float(rnd.Intn(21) - 10), Y: aoi.Float(rnd.Intn(5)), Z: aoi.Float(rnd.Intn(21) - 10)}
+				maxDist := aoi.Float(rnd.Intn(120))
+				if q%10 == 0 {
+					maxDist = aoi.FloatInf(1)
+				}
+				ray, ok := aoi.NewRay(origin, aoi.Position{X: dir.X, Z: dir.Z})
+				if !ok {
+					continue
+				}
+				var want []aoi.RaycastHit
+				for id, p := range pos {
+					if d, ok := ray.Hit(p, body[id]); ok && d <= maxDist {
+						want = append(want, aoi.RaycastHit{ID: id, Dist: d})
+					}
+				}
+				aoi.SortHits(want)
+				if got := m.Raycast(origin, dir, maxDist); !slices.Equal(got, want) {
+					t.Fatalf("Raycast(%v, %v, %v) = %v, want %v", origin, dir, maxDist, got, want)
+				}
+			}
+		})
+	}
+}
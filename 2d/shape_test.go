@@ -0,0 +1,49 @@
+package two_dim
+
+import (
+	"math"
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestFacing(t *testing.T) {
+	m := NewManager(10, 0, 0, 100, 100)
+	cb := &countingCallback{}
+	m.SetCallback(cb)
+	m.AddPlayer(1)
+	m.AddEntity(1, &aoi.Position{X: 50, Z: 50}, 25)
+	m.Subscribe(1, 1)
+	m.AddEntity(2, &aoi.Position{X: 60, Z: 50}, 0) // 正前方 (+X)
+	m.AddEntity(3, &aoi.Position{X: 40, Z: 50}, 0) // 正后方
+
+	// 面向 +X，张角 90 度
+	m.SetFacing(1, &aoi.Position{X: 1}, math.Pi/2)
+	if !m.CanSee(1, 2) || m.CanSee(1, 3) {
+		t.Fatal("guard should only see what is in front of it")
+	}
+	// 边界上 (45 度) 的目标算在视野内
+	m.AddEntity(4, &aoi.Position{X: 60, Z: 60}, 0)
+	if !m.CanSee(1, 4) {
+		t.Fatal("target on the cone boundary should be visible")
+	}
+
+	// 只转身不移动
+	enter, leave := cb.enter, cb.leave
+	m.SetFacing(1, &aoi.Position{X: -1}, math.Pi/2)
+	if m.CanSee(1, 2) || !m.CanSee(1, 3) || cb.enter != enter+1 || cb.leave != leave+2 {
+		t.Fatalf("turning around should swap the view, enter=%d leave=%d", cb.enter-enter, cb.leave-leave)
+	}
+
+	// 目标绕到背后
+	m.MoveEntity(3, &aoi.Position{X: 55, Z: 50})
+	if m.CanSee(1, 3) {
+		t.Fatal("target moving behind the guard should leave")
+	}
+
+	// 取消朝向限制
+	m.SetFacing(1, nil, 0)
+	if !m.CanSee(1, 2) || !m.CanSee(1, 3) || !m.CanSee(1, 4) {
+		t.Fatal("clearing the facing should restore the full circle")
+	}
+}
@@ -0,0 +1,51 @@
+package two_dim
+
+import (
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/internal/sparse"
+)
+
+// sparseIndex 按 XZ 平面上的格子坐标哈希的稀疏格子，没有地图范围
+// 格子在第一个实体进入时创建，最后一个实体离开时释放，内存只与实体数有关
+type sparseIndex struct {
+	cells *sparse.Cells[Grid]
+}
+
+// NewSparseManager 创建没有地图范围的稀疏格子管理器 (程序生成的、近似无限大的地图)
+// 接口、订阅语义与回调行为与 NewManager 相同，只是没有越界的概念
+func NewSparseManager(gridSize int) *Manager {
+	return newManager(&flatIndex{cells: newSparseIndex(aoi.Float(gridSize))})
+}
+
+func newSparseIndex(gridSize aoi.Float) *sparseIndex {
+	return &sparseIndex{cells: sparse.New[Grid](gridSize, 2)}
+}
+
+func (s *sparseIndex) cellOf(pos *aoi.Position) *Grid {
+	key := s.cells.KeyOf(*pos)
+	g := s.cells.Get(key)
+	if g == nil {
+		g = newGrid()
+		g.key = key
+		s.cells.Put(key, g)
+	}
+	return g
+}
+
+func (s *sparseIndex) release(g *Grid) {
+	s.cells.Release(g.key)
+}
+
+func (s *sparseIndex) forEachCell(pos *aoi.Position, radius aoi.Float, fn func(g *Grid)) {
+	s.cells.ForEachInBox(*pos, radius, fn)
+}
+
+// layout 格线从原点开始
+func (s *sparseIndex) layout() (aoi.Float, aoi.Float, aoi.Float) {
+	return s.cells.Size(), 0, 0
+}
+
+// accepts 没有地图范围，任何位置都可以
+func (s *sparseIndex) accepts(*aoi.Position) bool {
+	return true
+}
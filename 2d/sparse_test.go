@@ -0,0 +1,41 @@
+package two_dim
+
+import (
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestSparseCells(t *testing.T) {
+	m := NewSparseManager(10)
+	s := m.index.(*flatIndex).cells.(*sparseIndex)
+	cb := &countingCallback{}
+	m.SetCallback(cb)
+	m.AddPlayer(1)
+	// 远离原点、跨越负坐标的世界
+	m.AddEntity(1, &aoi.Position{X: -1e9, Z: 3e9}, 15)
+	m.Subscribe(1, 1)
+	m.AddEntity(2, &aoi.Position{X: -1e9 + 12, Z: 3e9}, 0)
+	m.AddEntity(3, &aoi.Position{X: 2, Z: -2}, 0)
+	if !m.CanSee(1, 2) || m.CanSee(1, 3) || s.cells.Len() != 3 {
+		t.Fatalf("cells = %d", s.cells.Len())
+	}
+	// 格子随实体创建和释放
+	m.MoveEntity(2, &aoi.Position{X: -1e9 + 5, Z: 3e9})
+	m.RemoveEntity(3)
+	if !m.CanSee(1, 2) || s.cells.Len() != 1 {
+		t.Fatalf("empty cells should be freed, cells = %d", s.cells.Len())
+	}
+	// 视野半径远大于格子时遍历现有的格子
+	m.SetRange(1, 1e12)
+	m.AddEntity(3, &aoi.Position{X: 5e11, Z: 0}, 0)
+	if !m.CanSee(1, 3) || cb.enter != 2 {
+		t.Fatal("huge ranges should still find every target")
+	}
+	m.RemoveEntity(1)
+	m.RemoveEntity(2)
+	m.RemoveEntity(3)
+	if s.cells.Len() != 0 {
+		t.Fatalf("all cells should be freed, cells = %d", s.cells.Len())
+	}
+}
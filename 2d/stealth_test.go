@@ -0,0 +1,51 @@
+package two_dim
+
+import (
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestStealth(t *testing.T) {
+	m := NewManager(10, 0, 0, 100, 100)
+	m.AddPlayer(1)
+	m.AddEntity(1, &aoi.Position{X: 50, Z: 50}, 30) // 守卫
+	m.Subscribe(1, 1)
+	m.SetStealth(1, aoi.Stealth{Detection: 1, RevealRadius: 5})
+	m.AddEntity(2, &aoi.Position{X: 70, Z: 50}, 10) // 盗贼
+	m.SetStealth(2, aoi.Stealth{Level: 2})
+	if m.CanSee(1, 2) {
+		t.Fatal("stealthed rogue should be hidden from a low-detection guard")
+	}
+
+	// 走进揭示半径
+	m.MoveEntity(2, &aoi.Position{X: 54, Z: 50})
+	if !m.CanSee(1, 2) {
+		t.Fatal("rogue inside the reveal radius should be visible")
+	}
+	// 高度不同不影响 XZ 平面上的揭示半径
+	m.MoveEntity(2, &aoi.Position{X: 54, Y: 30, Z: 50})
+	if !m.CanSee(1, 2) {
+		t.Fatal("reveal radius should ignore Y in the 2D manager")
+	}
+	m.MoveEntity(2, &aoi.Position{X: 60, Z: 50})
+	if m.CanSee(1, 2) {
+		t.Fatal("rogue leaving the reveal radius should be hidden again")
+	}
+
+	// 侦测等级提升
+	m.SetStealth(1, aoi.Stealth{Detection: 2})
+	if !m.CanSee(1, 2) {
+		t.Fatal("guard with enough detection should see the rogue")
+	}
+
+	// 自定义规则
+	m.SetVisibilityRule(aoi.VisibilityRuleFunc(func(w, tgt aoi.RuleSubject) bool { return tgt.ID != 2 }))
+	if m.CanSee(1, 2) {
+		t.Fatal("custom rule should hide entity 2")
+	}
+	m.SetVisibilityRule(nil)
+	if !m.CanSee(1, 2) {
+		t.Fatal("resetting the rule should fall back to the stealth rule")
+	}
+}
@@ -0,0 +1,32 @@
+package two_dim
+
+import (
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/internal/strict"
+)
+
+// strictChecks 严格模式对 Manager 的校验
+type strictChecks struct {
+	m *Manager
+}
+
+// Strict 返回严格模式的视图，与 m 共享同一份数据
+func (m *Manager) Strict() aoi.StrictAOIManager {
+	return strict.New(m, strictChecks{m: m})
+}
+
+func (c strictChecks) HasPlayer(id aoi.PlayerID) bool {
+	_, ok := c.m.players[id]
+	return ok
+}
+
+func (c strictChecks) HasEntity(id aoi.EntityID) bool {
+	_, ok := c.m.entities[id]
+	return ok
+}
+
+// ValidPos 位置必须落在地图范围内，越界策略为 OutOfBoundsOverflow 或稀疏格子时只拒绝 NaN
+// (普通接口会按越界策略把实体放进边缘的格子或者直接忽略)
+func (c strictChecks) ValidPos(pos *aoi.Position) bool {
+	return !pos.X.IsNaN() && !pos.Z.IsNaN() && c.m.index.accepts(pos)
+}
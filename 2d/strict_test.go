@@ -0,0 +1,51 @@
+package two_dim
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestStrict(t *testing.T) {
+	m := NewManager(10, 0, 0, 100, 100)
+	s := m.Strict()
+	check := func(step string, err, want error) {
+		t.Helper()
+		if !errors.Is(err, want) {
+			t.Fatalf("%s: err = %v, want %v", step, err, want)
+		}
+	}
+	check("add player", s.AddPlayer(1), nil)
+	check("duplicate player", s.AddPlayer(1), aoi.ErrDuplicatePlayer)
+	check("add entity", s.AddEntity(1, &aoi.Position{X: 50, Z: 50}, 20), nil)
+	check("duplicate entity", s.AddEntity(1, &aoi.Position{X: 10, Z: 10}, 20), aoi.ErrDuplicateEntity)
+	if m.entities[1].pos.X != 50 || len(m.dense().grids[1][1].entities) != 0 {
+		t.Fatal("duplicate AddEntity must not touch the existing entity")
+	}
+	check("nil pos", s.AddEntity(2, nil, 20), aoi.ErrNilPosition)
+	check("out of bounds", s.AddEntity(2, &aoi.Position{X: 150, Z: 50}, 20), aoi.ErrOutOfBounds)
+	check("move unknown", s.MoveEntity(2, &aoi.Position{X: 50, Z: 50}), aoi.ErrEntityNotFound)
+	check("move out of bounds", s.MoveEntity(1, &aoi.Position{X: 50, Z: -1}), aoi.ErrOutOfBounds)
+	check("set range unknown", s.SetRange(2, 5), aoi.ErrEntityNotFound)
+	check("subscribe missing entity", s.Subscribe(1, 2), aoi.ErrEntityNotFound)
+	check("subscribe missing player", s.Subscribe(2, 1), aoi.ErrPlayerNotFound)
+	check("subscribe", s.Subscribe(1, 1), nil)
+	check("add target", s.AddEntity(2, &aoi.Position{X: 60, Z: 50}, 0), nil)
+	if ok, err := s.CanSee(1, 2); err != nil || !ok {
+		t.Fatalf("CanSee = %v, %v", ok, err)
+	}
+	if _, err := s.GetView(2); !errors.Is(err, aoi.ErrPlayerNotFound) {
+		t.Fatalf("GetView of unknown player: err = %v", err)
+	}
+	check("remove", s.RemoveEntity(2), nil)
+	check("remove twice", s.RemoveEntity(2), aoi.ErrEntityNotFound)
+	check("remove player", s.RemovePlayer(1, true), nil)
+	check("remove player twice", s.RemovePlayer(1, true), aoi.ErrPlayerNotFound)
+
+	// 溢出桶策略下越界是合法的，NaN 仍然不行
+	m.SetOutOfBoundsPolicy(OutOfBoundsOverflow)
+	check("overflow", s.AddEntity(3, &aoi.Position{X: 150, Z: 50}, 20), nil)
+	check("overflow NaN", s.MoveEntity(3, &aoi.Position{X: aoi.Float(math.NaN()), Z: 50}), aoi.ErrOutOfBounds)
+}
@@ -1,7 +1,12 @@
 package three_dim
 
 import (
+	"time"
+
 	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/internal/maxtrack"
+	"github.com/beijian128/aoi/internal/players"
+	"github.com/beijian128/aoi/internal/view"
 )
 
 // MarkerType 节点类型
@@ -22,20 +27,42 @@ type Marker struct {
 
 	prev *Marker
 	next *Marker
+
+	// indexed 是否在 AxisList.index 中，detached 是否已经从链表中断开 (实体已移除)
+	indexed, detached bool
 }
 
 // AxisList 双向链表
 type AxisList struct {
 	Head *Marker // -Inf
 	Tail *Marker // +Inf
+
+	// index 链表的抽样节点 (大致有序)，插入时二分查找出起点，再沿链表走到准确位置
+	index []*Marker
+	// detached index 中已经断开的节点数
+	detached int
 }
 
 // Entity 物理实体 (物理层)
 // 它是视野的提供者，也是被观察的对象
 type Entity struct {
-	ID    aoi.EntityID
-	Pos   [3]aoi.Float
-	Range aoi.Float // 视野半径 (立方体半边长)
+	ID  aoi.EntityID
+	Pos [3]aoi.Float
+	// RangeMin/RangeMax: 每个轴上视野边界相对 Pos 的偏移 (RangeMin <= RangeMax)
+	// 视野区间为 [Pos+RangeMin, Pos+RangeMax]，立方体视野时为 [-Range, Range]
+	RangeMin, RangeMax [3]aoi.Float
+	// Shape 精确视野形状，ShapeBox 时只用视野盒判定
+	Shape aoi.Shape
+	// Facing 朝向与视野张角，未设置时为全向视野
+	Facing aoi.Facing
+	// version 每次移动加一，用于判断视线检测的缓存是否失效
+	version uint64
+	// Emit 我属于哪些层，Sense 我能感知哪些层
+	Emit, Sense aoi.LayerMask
+	// Stealth 潜行/侦测属性，由可见性规则使用
+	Stealth aoi.Stealth
+	// Body 包围半径，只用于射线检测
+	Body aoi.Float
 
 	// 链表节点: [3个轴][3种类型]
 	Markers [3][3]*Marker
@@ -44,20 +71,52 @@ type Entity struct {
 	// Key: TargetID
 	// Value: 轴匹配数 (0-3). 当且仅当 == 3 时，物理上可见
 	ViewCounts map[aoi.EntityID]int
+	// CountedBy: ViewCounts 中有我的实体 (ViewCounts 的反向索引)，移除实体时用来直接撤销计数
+	CountedBy map[aoi.EntityID]*Entity
 
-	// VisibleSet: 当前物理上真正看见的集合 (ViewCounts==3 的子集)
+	// InBox: 落在我视野盒 (加上离开余量) 内的实体 (ViewCounts==3)
+	InBox map[aoi.EntityID]*Entity
+	// Watchers: 视野盒包含我的实体 (InBox 的反向索引)
+	Watchers map[aoi.EntityID]*Entity
+
+	// VisibleSet: 当前物理上真正看见的集合 (InBox 中通过形状、朝向、视线判定的子集)
 	VisibleSet map[aoi.EntityID]bool
 
+	// los 视线检测缓存 (只保存 InBox 中的目标)
+	los map[aoi.EntityID]losResult
+
 	// Subscribers: 哪些玩家订阅了我的视野
 	// Key: PlayerID
 	Subscribers map[aoi.PlayerID]*aoi.Player
 }
 
+// losResult 一次视线检测的结果，双方的 version 都没变时有效
+type losResult struct {
+	watcherVersion, targetVersion uint64
+	blocked                       bool
+}
+
 // Manager AOI 管理器
 type Manager struct {
-	axes          [3]*AxisList
-	entities      map[aoi.EntityID]*Entity
-	players       map[aoi.PlayerID]*aoi.Player
+	axes     [3]*AxisList
+	entities map[aoi.EntityID]*Entity
+	players  map[aoi.PlayerID]*aoi.Player
+	occluder aoi.Occluder
+	rule     aoi.VisibilityRule // 为 nil 时使用 aoi.StealthRule
+	// leaveMargin 离开视野的余量：进入按视野盒判定，离开要超出视野盒 leaveMargin 才算
+	// Min/Max 节点按扩大之后的视野盒放置
+	leaveMargin aoi.Float
+	// maxWidth 每个轴上 Min/Max 节点之间最大距离的上界 (只增不减)
+	// 新实体作为目标时，只有 Pos 左边这个距离内的 Min 节点才可能包含它
+	maxWidth [3]aoi.Float
+	// widthStale 最宽的实体被移除之后 maxWidth 可能偏大 (仍然正确，只是多扫描一些节点)
+	// widthOps 此后累计的移除/移动次数，达到实体数时才重新统计，均摊到每次操作是 O(1)
+	widthStale bool
+	widthOps   int
+	// maxBody 所有实体中最大的包围半径 (射线检测时按它扩大扫描范围)
+	maxBody maxtrack.Tracker
+	// grace 延迟 Leave 的时钟与宽限期
+	grace         view.Grace
 	eventCallback aoi.AOICallback
 }
 
@@ -66,6 +125,9 @@ func (m *Manager) CanSee(watcherId aoi.PlayerID, targetId aoi.EntityID) bool {
 	if watcher == nil {
 		return false
 	}
+	if _, ok := watcher.PendingLeave[targetId]; ok {
+		return true
+	}
 	return watcher.FinalView[targetId] > 0
 }
 
@@ -92,58 +154,70 @@ func (m *Manager) SetCallback(cb aoi.AOICallback) {
 // AddPlayer 注册玩家
 func (m *Manager) AddPlayer(id aoi.PlayerID) {
 	if _, ok := m.players[id]; !ok {
-		m.players[id] = &aoi.Player{
-			ID:        id,
-			FinalView: make(map[aoi.EntityID]int),
-		}
+		m.players[id] = players.New(id)
 	}
 }
 
-// AddEntity 添加物理单位
+// RemovePlayer 移除玩家 (例如客户端断线)，取消它对所有实体的订阅
+// emitLeave 为 true 时对视野内的所有目标 (包括宽限期中的) 触发 OnLeave
+func (m *Manager) RemovePlayer(id aoi.PlayerID, emitLeave bool) {
+	players.Drop(m.players, m.entities, id, emitLeave, m.eventCallback, func(e *Entity) {
+		delete(e.Subscribers, id)
+	})
+}
+
+// AddEntity 添加物理单位 (立方体视野，rangeVal 为半边长)
 func (m *Manager) AddEntity(id aoi.EntityID, pos *aoi.Position, rangeVal aoi.Float) {
+	m.AddEntityBox(id, pos, aoi.CubeBox(rangeVal))
+}
+
+// AddEntityBox 以长方体视野添加物理单位
+func (m *Manager) AddEntityBox(id aoi.EntityID, pos *aoi.Position, box aoi.Box) {
+	if pos == nil {
+		return
+	}
 	if _, ok := m.entities[id]; ok {
 		return
 	}
-	x, y, z := pos.X, pos.Y, pos.Z
+	e := newEntity(id, *pos, box)
+	m.createMarkers(e)
+
+	// 直接插入到有序位置，不经过节点穿越，也就不会产生中间过程的 Enter/Leave
+	for axis := 0; axis < 3; axis++ {
+		for typeIdx := 0; typeIdx < 3; typeIdx++ {
+			m.axes[axis].insert(e.Markers[axis][typeIdx])
+		}
+	}
+	m.entities[id] = e
+	m.noteWidth(e)
+	m.countOverlaps(e)
+}
+
+func newEntity(id aoi.EntityID, pos aoi.Position, box aoi.Box) *Entity {
 	e := &Entity{
 		ID:          id,
-		Pos:         [3]aoi.Float{x, y, z},
-		Range:       rangeVal,
+		Pos:         [3]aoi.Float{pos.X, pos.Y, pos.Z},
+		Emit:        aoi.LayerAll,
+		Sense:       aoi.LayerAll,
 		ViewCounts:  make(map[aoi.EntityID]int),
+		CountedBy:   make(map[aoi.EntityID]*Entity),
+		InBox:       make(map[aoi.EntityID]*Entity),
+		Watchers:    make(map[aoi.EntityID]*Entity),
 		VisibleSet:  make(map[aoi.EntityID]bool),
+		los:         make(map[aoi.EntityID]losResult),
 		Subscribers: make(map[aoi.PlayerID]*aoi.Player),
 	}
+	e.RangeMin, e.RangeMax = boxOffsets(box)
+	return e
+}
 
-	// 创建并链接节点
-	vals := [3]aoi.Float{x, y, z}
+// createMarkers 创建 e 在三个轴上的节点 (还没有链接到链表中)
+func (m *Manager) createMarkers(e *Entity) {
 	for axis := 0; axis < 3; axis++ {
-		// 创建
-		e.Markers[axis][MarkerMin] = &Marker{Type: MarkerMin, Axis: axis, Val: vals[axis] - rangeVal, Owner: e}
-		e.Markers[axis][MarkerMax] = &Marker{Type: MarkerMax, Axis: axis, Val: vals[axis] + rangeVal, Owner: e}
-		e.Markers[axis][MarkerPos] = &Marker{Type: MarkerPos, Axis: axis, Val: vals[axis], Owner: e}
-
-		// 简单插入到尾部前 (依靠后面的 Update 进行排序)
-		list := m.axes[axis]
-		prev := list.Tail.prev
-
-		// 链接 Min
-		prev.next = e.Markers[axis][MarkerMin]
-		e.Markers[axis][MarkerMin].prev = prev
-		// 链接 Pos
-		e.Markers[axis][MarkerMin].next = e.Markers[axis][MarkerPos]
-		e.Markers[axis][MarkerPos].prev = e.Markers[axis][MarkerMin]
-		// 链接 Max
-		e.Markers[axis][MarkerPos].next = e.Markers[axis][MarkerMax]
-		e.Markers[axis][MarkerMax].prev = e.Markers[axis][MarkerPos]
-		// 链接 Tail
-		e.Markers[axis][MarkerMax].next = list.Tail
-		list.Tail.prev = e.Markers[axis][MarkerMax]
+		e.Markers[axis][MarkerMin] = &Marker{Type: MarkerMin, Axis: axis, Val: m.viewMin(e, axis), Owner: e}
+		e.Markers[axis][MarkerMax] = &Marker{Type: MarkerMax, Axis: axis, Val: m.viewMax(e, axis), Owner: e}
+		e.Markers[axis][MarkerPos] = &Marker{Type: MarkerPos, Axis: axis, Val: e.Pos[axis], Owner: e}
 	}
-
-	m.entities[id] = e
-
-	// 立即更新位置以触发正确的排序和AOI计算
-	m.updateEntity(e, x, y, z)
 }
 
 // RemoveEntity 移除物理单位
@@ -153,28 +227,219 @@ func (m *Manager) RemoveEntity(id aoi.EntityID) {
 		return
 	}
 
-	// 1. 触发该单位视野的丢失 (通知订阅者)
-	for targetID := range e.VisibleSet {
-		m.notifySubscribers(e, targetID, false)
+	// 1. 撤销 e 作为观察者的所有计数
+	for tid := range e.ViewCounts {
+		target := m.entities[tid]
+		delete(target.CountedBy, id)
+		delete(target.Watchers, id)
+	}
+	for tid := range e.VisibleSet {
+		view.Notify(e.Subscribers, tid, -1, m.eventCallback, &m.grace)
 	}
 
-	m.updateEntity(e, 999999, 999999, 999999)
+	// 2. 撤销别人对 e 的计数 (e 作为目标)
+	for _, watcher := range e.CountedBy {
+		delete(watcher.ViewCounts, id)
+		delete(watcher.InBox, id)
+		delete(watcher.los, id)
+		if watcher.VisibleSet[id] {
+			delete(watcher.VisibleSet, id)
+			view.Notify(watcher.Subscribers, id, -1, m.eventCallback, &m.grace)
+		}
+	}
 
-	// 3. 物理断开
+	// 3. 物理断开，代价只与 e 实际的重叠数有关，与世界大小无关
 	for axis := 0; axis < 3; axis++ {
 		for typeIdx := 0; typeIdx < 3; typeIdx++ {
-			node := e.Markers[axis][typeIdx]
-			node.prev.next = node.next
-			node.next.prev = node.prev
+			m.axes[axis].unlink(e.Markers[axis][typeIdx])
 		}
 	}
 	delete(m.entities, id)
+	players.DropEntity(e.Subscribers, id)
+	m.shrinkWidth(e)
+	if m.maxBody.Remove(e.Body) {
+		m.resetMaxBody()
+	}
 }
 
 func (m *Manager) MoveEntity(id aoi.EntityID, pos *aoi.Position) {
+	if pos == nil {
+		return
+	}
 	if e, ok := m.entities[id]; ok {
 		m.updateEntity(e, pos.X, pos.Y, pos.Z)
+		// 在视野盒内移动不会产生节点穿越，但可能跨过球面/圆柱面
+		m.refreshShape(e)
+	}
+}
+
+// SetRange 修改视野半径
+// 球形、圆柱形视野修改的是 Radius (圆柱的高度不变)，其它情况为立方体视野
+func (m *Manager) SetRange(id aoi.EntityID, r aoi.Float) {
+	e, ok := m.entities[id]
+	if !ok {
+		return
+	}
+	if r < 0 {
+		r = 0
+	}
+	if e.Shape.Kind == aoi.ShapeBox {
+		m.setBox(e, aoi.CubeBox(r))
+		// 离开余量内的目标已经在 InBox 中，节点不会穿越它，需要重新判定
+		for _, target := range e.InBox {
+			m.updateVisible(e, target)
+		}
+		return
+	}
+	shape := e.Shape
+	shape.Radius = r
+	m.SetShape(id, shape)
+}
+
+// SetBox 修改视野盒，同时取消精确视野形状
+func (m *Manager) SetBox(id aoi.EntityID, box aoi.Box) {
+	e, ok := m.entities[id]
+	if !ok {
+		return
+	}
+	e.Shape = aoi.Shape{}
+	m.setBox(e, box)
+	// 原先被形状过滤掉的目标可能重新可见
+	for _, target := range e.InBox {
+		m.updateVisible(e, target)
+	}
+}
+
+// SetShape 设置精确视野形状，视野盒改为形状的外接盒
+func (m *Manager) SetShape(id aoi.EntityID, shape aoi.Shape) {
+	e, ok := m.entities[id]
+	if !ok {
+		return
+	}
+	shape.Radius = max(shape.Radius, 0)
+	shape.HalfHeight = max(shape.HalfHeight, 0)
+	e.Shape = shape
+	if shape.Kind != aoi.ShapeBox {
+		m.setBox(e, shape.Bounds())
+	}
+	for _, target := range e.InBox {
+		m.updateVisible(e, target)
+	}
+}
+
+// SetFacing 设置朝向与视野张角 (弧度)，facing 为 nil 时取消朝向限制
+// 只转身时视野盒不变，只需要重新判定视野盒内的实体
+func (m *Manager) SetFacing(id aoi.EntityID, facing *aoi.Position, fov aoi.Float) {
+	e, ok := m.entities[id]
+	if !ok {
+		return
+	}
+	if facing == nil {
+		e.Facing = aoi.Facing{}
+	} else {
+		e.Facing = aoi.Facing{Dir: *facing, FOV: fov}
+	}
+	for _, target := range e.InBox {
+		m.updateVisible(e, target)
+	}
+}
+
+// SetLayers 设置可见性层
+// 视野盒不受影响，只需要重新判定视野盒内 (双向) 的实体对
+func (m *Manager) SetLayers(id aoi.EntityID, emit, sense aoi.LayerMask) {
+	e, ok := m.entities[id]
+	if !ok {
+		return
+	}
+	e.Emit, e.Sense = emit, sense
+	m.refreshPairs(e)
+}
+
+// SetStealth 设置潜行/侦测属性，同样只影响视野盒内的实体对
+func (m *Manager) SetStealth(id aoi.EntityID, st aoi.Stealth) {
+	e, ok := m.entities[id]
+	if !ok {
+		return
+	}
+	e.Stealth = st
+	m.refreshPairs(e)
+}
+
+// SetVisibilityRule 设置可见性规则，并重新判定所有视野盒内的实体对
+// ViewCounts 只记录几何关系，不受规则影响
+func (m *Manager) SetVisibilityRule(r aoi.VisibilityRule) {
+	m.rule = r
+	for _, e := range m.entities {
+		for _, target := range e.InBox {
+			m.updateVisible(e, target)
+		}
+	}
+}
+
+// refreshPairs 重新判定 e 与视野盒内实体之间 (双向) 的可见性
+func (m *Manager) refreshPairs(e *Entity) {
+	for _, target := range e.InBox {
+		m.updateVisible(e, target)
+	}
+	for _, watcher := range e.Watchers {
+		m.updateVisible(watcher, e)
+	}
+}
+
+// SetLeaveMargin 设置离开视野的余量，避免目标在视野边界上来回走动时反复 Enter/Leave
+// 进入仍按视野范围判定，已经可见的目标要超出视野范围 margin 才会 Leave
+func (m *Manager) SetLeaveMargin(margin aoi.Float) {
+	if margin < 0 {
+		margin = 0
+	}
+	m.leaveMargin = margin
+	// 余量总是非负，单独移动 Min 或 Max 都不会让区间翻转
+	for _, e := range m.entities {
+		for axis := 0; axis < 3; axis++ {
+			m.updateMarker(e.Markers[axis][MarkerMin], m.viewMin(e, axis))
+			m.updateMarker(e.Markers[axis][MarkerMax], m.viewMax(e, axis))
+		}
+	}
+	m.resetMaxWidth()
+}
+
+// viewMin/viewMax 实体在 axis 轴上 Min/Max 节点的位置 (视野盒加上离开余量)
+func (m *Manager) viewMin(e *Entity, axis int) aoi.Float {
+	return e.Pos[axis] + e.RangeMin[axis] - m.leaveMargin
+}
+
+func (m *Manager) viewMax(e *Entity, axis int) aoi.Float {
+	return e.Pos[axis] + e.RangeMax[axis] + m.leaveMargin
+}
+
+// setBox 修改视野盒
+// 只需要移动三个轴上的 Min/Max 节点，由节点穿越产生 Enter/Leave，实体本身不需要重新插入
+func (m *Manager) setBox(e *Entity, box aoi.Box) {
+	oldMin := e.RangeMin
+	e.RangeMin, e.RangeMax = boxOffsets(box)
+	for axis := 0; axis < 3; axis++ {
+		// 下界右移时先动上界，否则先动下界，保证过程中 Min <= Max
+		if e.RangeMin[axis] > oldMin[axis] {
+			m.updateMarker(e.Markers[axis][MarkerMax], m.viewMax(e, axis))
+			m.updateMarker(e.Markers[axis][MarkerMin], m.viewMin(e, axis))
+		} else {
+			m.updateMarker(e.Markers[axis][MarkerMin], m.viewMin(e, axis))
+			m.updateMarker(e.Markers[axis][MarkerMax], m.viewMax(e, axis))
+		}
 	}
+	m.noteWidth(e)
+}
+
+// boxOffsets 把视野盒转换为每个轴上的偏移，Min > Max 的轴会被交换
+func boxOffsets(box aoi.Box) (min, max [3]aoi.Float) {
+	min = [3]aoi.Float{box.Min.X, box.Min.Y, box.Min.Z}
+	max = [3]aoi.Float{box.Max.X, box.Max.Y, box.Max.Z}
+	for axis := 0; axis < 3; axis++ {
+		if min[axis] > max[axis] {
+			min[axis], max[axis] = max[axis], min[axis]
+		}
+	}
+	return min, max
 }
 
 // Subscribe 视野订阅
@@ -190,10 +455,11 @@ func (m *Manager) Subscribe(playerID aoi.PlayerID, entityID aoi.EntityID) {
 	}
 
 	e.Subscribers[playerID] = p
+	p.Subscriptions.Add(entityID)
 
 	// 立即同步当前视野
 	for targetID := range e.VisibleSet {
-		m.refCountChange(p, targetID, 1)
+		view.Change(p, targetID, 1, m.eventCallback, &m.grace)
 	}
 }
 
@@ -211,10 +477,11 @@ func (m *Manager) Unsubscribe(playerID aoi.PlayerID, entityID aoi.EntityID) {
 
 	// 解除关系
 	delete(e.Subscribers, playerID)
+	p.Subscriptions.Remove(entityID)
 
 	// 立即移除贡献
 	for targetID := range e.VisibleSet {
-		m.refCountChange(p, targetID, -1)
+		view.Change(p, targetID, -1, m.eventCallback, &m.grace)
 	}
 }
 
@@ -228,32 +495,67 @@ func (m *Manager) GetView(id aoi.PlayerID) aoi.Set[aoi.EntityID] {
 	for tid := range p.FinalView {
 		res.Add(tid)
 	}
+	// 宽限期内的目标仍然算在视野内
+	for tid := range p.PendingLeave {
+		res.Add(tid)
+	}
 	return res
 }
 
 func (m *Manager) updateEntity(e *Entity, x, y, z aoi.Float) {
 	e.Pos = [3]aoi.Float{x, y, z}
+	e.version++
 	newVals := [3]aoi.Float{x, y, z}
 
 	for axis := 0; axis < 3; axis++ {
-		m.updateMarker(e.Markers[axis][MarkerMin], newVals[axis]-e.Range)
-		m.updateMarker(e.Markers[axis][MarkerPos], newVals[axis])
-		m.updateMarker(e.Markers[axis][MarkerMax], newVals[axis]+e.Range)
+		// 按移动方向决定更新顺序：向右时先动 Max，向左时先动 Min，
+		// 保证任何时刻 Min <= Max，否则区间翻转会让计数变成负数
+		if newVals[axis] > e.Markers[axis][MarkerPos].Val {
+			m.updateMarker(e.Markers[axis][MarkerMax], m.viewMax(e, axis))
+			m.updateMarker(e.Markers[axis][MarkerPos], newVals[axis])
+			m.updateMarker(e.Markers[axis][MarkerMin], m.viewMin(e, axis))
+		} else {
+			m.updateMarker(e.Markers[axis][MarkerMin], m.viewMin(e, axis))
+			m.updateMarker(e.Markers[axis][MarkerPos], newVals[axis])
+			m.updateMarker(e.Markers[axis][MarkerMax], m.viewMax(e, axis))
+		}
+	}
+	// 浮点误差会让移动之后的区间宽度有细微的变化
+	m.noteWidth(e)
+	m.shrinkWidth(nil)
+}
 
+// markerRank 坐标相同时的排列顺序：Min < Pos < Max，即视野边界上的目标算作可见
+func markerRank(t MarkerType) int {
+	switch t {
+	case MarkerMin:
+		return 0
+	case MarkerPos:
+		return 1
+	default:
+		return 2
 	}
 }
 
+// markerLess a 是否应该排在 b 的前面
+func markerLess(a, b *Marker) bool {
+	if a.Val != b.Val {
+		return a.Val < b.Val
+	}
+	return markerRank(a.Type) < markerRank(b.Type)
+}
+
 func (m *Manager) updateMarker(node *Marker, newVal aoi.Float) {
 	node.Val = newVal
 
 	// 向右移动 (Val 变大)
-	for node.next != nil && !node.next.Val.IsInf(0) && node.Val > node.next.Val {
+	for node.next != nil && !node.next.Val.IsInf(0) && markerLess(node.next, node) {
 		other := node.next
 		m.swap(node, other) // node 换到 other 后面
 		m.checkCross(node, other, true)
 	}
 	// 向左移动 (Val 变小)
-	for node.prev != nil && !node.prev.Val.IsInf(0) && node.Val < node.prev.Val {
+	for node.prev != nil && !node.prev.Val.IsInf(0) && markerLess(node, node.prev) {
 		other := node.prev
 		m.swap(other, node) // other 换到 node 后面 (即 node 换到 other 前面)
 		m.checkCross(node, other, false)
@@ -337,52 +639,143 @@ func (m *Manager) checkCross(mover, passive *Marker, movingRight bool) {
 	// 清理 map 防止内存泄漏
 	if newC <= 0 {
 		delete(watcher.ViewCounts, target.ID)
+		delete(target.CountedBy, watcher.ID)
 		newC = 0 // 修正为0以防逻辑错误
 	} else {
 		watcher.ViewCounts[target.ID] = newC
+		target.CountedBy[watcher.ID] = watcher
 	}
 
 	// (3轴全部进入)
 	if oldC < 3 && newC == 3 {
+		watcher.InBox[target.ID] = target
+		target.Watchers[watcher.ID] = watcher
+		m.updateVisible(watcher, target)
+	} else if oldC == 3 && newC < 3 {
+		delete(watcher.InBox, target.ID)
+		delete(target.Watchers, watcher.ID)
+		delete(watcher.los, target.ID)
+		m.updateVisible(watcher, target)
+	}
+}
+
+// updateVisible 根据视野盒与精确形状更新 watcher 对 target 的可见性，变化时通知订阅者
+func (m *Manager) updateVisible(watcher, target *Entity) {
+	want := false
+	if _, ok := watcher.InBox[target.ID]; ok {
+		want = m.passes(watcher, target)
+	}
+	if want && !watcher.VisibleSet[target.ID] {
 		// 物理 Enter
 		watcher.VisibleSet[target.ID] = true
-		m.notifySubscribers(watcher, target.ID, true)
-	} else if oldC == 3 && newC < 3 {
+		view.Notify(watcher.Subscribers, target.ID, 1, m.eventCallback, &m.grace)
+	} else if !want && watcher.VisibleSet[target.ID] {
 		// 物理 Leave
 		delete(watcher.VisibleSet, target.ID)
-		m.notifySubscribers(watcher, target.ID, false)
+		view.Notify(watcher.Subscribers, target.ID, -1, m.eventCallback, &m.grace)
 	}
 }
 
-// notifySubscribers 通知所有订阅者
-func (m *Manager) notifySubscribers(source *Entity, targetID aoi.EntityID, isEnter bool) {
-	delta := -1
-	if isEnter {
-		delta = 1
+// refreshShape e 移动之后，重新判定与视野盒内实体之间 (双向) 的精确判定
+// 结果与位置无关的实体对 (例如双方都只用视野盒判定) 不会有任何变化，直接跳过
+func (m *Manager) refreshShape(e *Entity) {
+	for _, target := range e.InBox {
+		if m.dependsOnPos(e, target) {
+			m.updateVisible(e, target)
+		}
 	}
+	for _, watcher := range e.Watchers {
+		if m.dependsOnPos(watcher, e) {
+			m.updateVisible(watcher, e)
+		}
+	}
+}
 
-	for _, player := range source.Subscribers {
-		m.refCountChange(player, targetID, delta)
+// dependsOnPos 视野盒内的 watcher->target 的判定结果是否会随位置变化
+func (m *Manager) dependsOnPos(watcher, target *Entity) bool {
+	return m.occluder != nil || m.rule != nil || m.leaveMargin > 0 || target.Stealth.Level > watcher.Stealth.Detection ||
+		watcher.Shape.Kind != aoi.ShapeBox || watcher.Facing.Enabled()
+}
+
+// passes 视野盒内的 target 是否通过 watcher 的精确判定 (可见性层、形状、朝向、可见性规则、视线)
+func (m *Manager) passes(watcher, target *Entity) bool {
+	if watcher.Sense&target.Emit == 0 {
+		return false
+	}
+	d := aoi.Position{
+		X: target.Pos[0] - watcher.Pos[0],
+		Y: target.Pos[1] - watcher.Pos[1],
+		Z: target.Pos[2] - watcher.Pos[2],
 	}
+	shape := watcher.Shape
+	if watcher.VisibleSet[target.ID] {
+		// 已经可见：离开时形状同样放宽 leaveMargin
+		shape.Radius += m.leaveMargin
+		shape.HalfHeight += m.leaveMargin
+	} else if m.leaveMargin > 0 && !inViewBox(watcher, d) {
+		// 还不可见：必须进入原始的视野盒
+		return false
+	}
+	return shape.Contains(d) && watcher.Facing.Contains(d) && m.ruleAllows(watcher, target) && !m.blocked(watcher, target)
 }
 
-// refCountChange 玩家引用计数变更
-func (m *Manager) refCountChange(p *aoi.Player, targetID aoi.EntityID, delta int) {
-	oldVal := p.FinalView[targetID]
-	newVal := oldVal + delta
+// inViewBox 相对 watcher 的偏移 d 是否落在 watcher 的视野盒 (不含余量) 内
+func inViewBox(watcher *Entity, d aoi.Position) bool {
+	off := [3]aoi.Float{d.X, d.Y, d.Z}
+	for axis := 0; axis < 3; axis++ {
+		if off[axis] < watcher.RangeMin[axis] || off[axis] > watcher.RangeMax[axis] {
+			return false
+		}
+	}
+	return true
+}
 
-	if newVal <= 0 {
-		delete(p.FinalView, targetID)
-	} else {
-		p.FinalView[targetID] = newVal
+// ruleAllows 可见性规则是否允许 watcher 看见 target
+func (m *Manager) ruleAllows(watcher, target *Entity) bool {
+	rule := m.rule
+	if rule == nil {
+		rule = aoi.StealthRule{}
+	}
+	return rule.Visible(
+		aoi.RuleSubject{ID: watcher.ID, Pos: aoi.Position{X: watcher.Pos[0], Y: watcher.Pos[1], Z: watcher.Pos[2]}, Stealth: watcher.Stealth},
+		aoi.RuleSubject{ID: target.ID, Pos: aoi.Position{X: target.Pos[0], Y: target.Pos[1], Z: target.Pos[2]}, Stealth: target.Stealth},
+	)
+}
+
+// blocked watcher 到 target 的视线是否被遮挡，结果缓存到任意一方移动为止
+func (m *Manager) blocked(watcher, target *Entity) bool {
+	if m.occluder == nil {
+		return false
+	}
+	if r, ok := watcher.los[target.ID]; ok && r.watcherVersion == watcher.version && r.targetVersion == target.version {
+		return r.blocked
 	}
+	from := aoi.Position{X: watcher.Pos[0], Y: watcher.Pos[1], Z: watcher.Pos[2]}
+	to := aoi.Position{X: target.Pos[0], Y: target.Pos[1], Z: target.Pos[2]}
+	b := m.occluder.Blocks(from, to)
+	watcher.los[target.ID] = losResult{watcherVersion: watcher.version, targetVersion: target.version, blocked: b}
+	return b
+}
 
-	// 触发回调 (0 -> 1 Enter, 1 -> 0 Leave)
-	if m.eventCallback != nil {
-		if oldVal == 0 && newVal > 0 {
-			m.eventCallback.OnEnter(p.ID, targetID)
-		} else if oldVal > 0 && newVal <= 0 {
-			m.eventCallback.OnLeave(p.ID, targetID)
+// SetOccluder 设置遮挡物，并重新判定所有视野盒内的实体对
+func (m *Manager) SetOccluder(o aoi.Occluder) {
+	m.occluder = o
+	for _, e := range m.entities {
+		clear(e.los)
+	}
+	for _, e := range m.entities {
+		for _, target := range e.InBox {
+			m.updateVisible(e, target)
 		}
 	}
 }
+
+// SetLeaveGrace 设置延迟 Leave 的时钟与宽限期
+func (m *Manager) SetLeaveGrace(clock aoi.Clock, grace time.Duration) {
+	m.grace.Set(m.players, m.eventCallback, clock, grace)
+}
+
+// Tick 投递所有已经到期的 Leave
+func (m *Manager) Tick() {
+	m.grace.Tick(m.players, m.eventCallback)
+}
@@ -15,7 +15,9 @@ type DebugEntity struct {
 	ID    int64      `json:"id"`
 	Type  string     `json:"type"` // "player" 或 "npc"
 	Pos   [3]float64 `json:"pos"`
-	Range [3]float64 `json:"range"`
+	Range [3]float64 `json:"range"` // 视野盒半边长
+	// Offset 视野盒中心相对 Pos 的偏移 (对称视野时为 0)
+	Offset [3]float64 `json:"offset"`
 }
 
 type DebugRelation struct {
@@ -38,12 +40,12 @@ func (m *Manager) MakeSnapshot() *DebugSnapshot {
 			Pos: [3]float64{
 				float64(e.Pos[0]), float64(e.Pos[1]), float64(e.Pos[2]),
 			},
-			// 你现在的代码 Range 是单个 Float (立方体)，这里转为数组传给前端以便兼容长方体逻辑
-			Range: [3]float64{
-				float64(e.Range), float64(e.Range), float64(e.Range),
-			},
 			Type: "npc",
 		}
+		for axis := 0; axis < 3; axis++ {
+			dEnt.Range[axis] = float64(e.RangeMax[axis]-e.RangeMin[axis]) / 2
+			dEnt.Offset[axis] = float64(e.RangeMax[axis]+e.RangeMin[axis]) / 2
+		}
 
 		// 2. 检查是否是 Player (逻辑层)
 		if p, isPlayer := m.players[aoi.PlayerID(id)]; isPlayer {
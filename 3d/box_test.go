@@ -0,0 +1,78 @@
+package three_dim
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+// 长方体视野：每个实体由同 ID 的玩家订阅，玩家视野必须与暴力判定一致
+func TestBoxRange(t *testing.T) {
+	factories := map[string]func() aoi.BoxRangeManager{
+		"crosslist": func() aoi.BoxRangeManager { return NewManager() },
+		"octree": func() aoi.BoxRangeManager {
+			return NewOctreeManager(aoi.Position{X: 50, Y: 50, Z: 50}, 50, DefaultLooseFactor)
+		},
+	}
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+			const n = 40
+			rnd := rand.New(rand.NewSource(1))
+			m := factory()
+			pos := make(map[aoi.EntityID]aoi.Position)
+			boxes := make(map[aoi.EntityID]aoi.Box)
+			randPos := func() aoi.Position {
+				return aoi.Position{X: aoi.Float(rnd.Intn(100)), Y: aoi.Float(rnd.Intn(100)), Z: aoi.Float(rnd.Intn(100))}
+			}
+			// 有的轴偏向一侧，有的轴 Min > Max (应被交换)，有的视野盒不包含自身
+			randBox := func() aoi.Box {
+				c := func() aoi.Float { return aoi.Float(rnd.Intn(61) - 30) }
+				return aoi.Box{Min: aoi.Position{X: c(), Y: c(), Z: c()}, Max: aoi.Position{X: c(), Y: c(), Z: c()}}
+			}
+			for i := aoi.EntityID(1); i <= n; i++ {
+				p, b := randPos(), randBox()
+				pos[i], boxes[i] = p, b
+				m.AddPlayer(aoi.PlayerID(i))
+				m.AddEntityBox(i, &p, b)
+				m.Subscribe(aoi.PlayerID(i), i)
+			}
+			for step := 0; step < 2000; step++ {
+				id := aoi.EntityID(rnd.Intn(n) + 1)
+				switch rnd.Intn(3) {
+				case 0:
+					boxes[id] = randBox()
+					m.SetBox(id, boxes[id])
+				case 1:
+					r := aoi.Float(rnd.Intn(20))
+					boxes[id] = aoi.CubeBox(r)
+					m.SetRange(id, r)
+				default:
+					p := randPos()
+					pos[id] = p
+					m.MoveEntity(id, &p)
+				}
+			}
+			for w := aoi.EntityID(1); w <= n; w++ {
+				for tgt := aoi.EntityID(1); tgt <= n; tgt++ {
+					want := w != tgt && inBox(pos[w], boxes[w], pos[tgt])
+					if got := m.CanSee(aoi.PlayerID(w), tgt); got != want {
+						t.Fatalf("CanSee(%d, %d) = %v, want %v", w, tgt, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func inBox(center aoi.Position, box aoi.Box, p aoi.Position) bool {
+	in := func(c, a, b, v aoi.Float) bool {
+		if a > b {
+			a, b = b, a
+		}
+		return v >= c+a && v <= c+b
+	}
+	return in(center.X, box.Min.X, box.Max.X, p.X) &&
+		in(center.Y, box.Min.Y, box.Max.Y, p.Y) &&
+		in(center.Z, box.Min.Z, box.Max.Z, p.Z)
+}
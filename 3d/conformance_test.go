@@ -0,0 +1,55 @@
+package three_dim
+
+import (
+	"testing"
+
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/aoitest"
+)
+
+func TestConformance(t *testing.T) {
+	aoitest.RunConformance(t, func() aoi.AOIManager {
+		return NewManager()
+	})
+}
+
+func TestOctreeConformance(t *testing.T) {
+	aoitest.RunConformance(t, func() aoi.AOIManager {
+		return NewOctreeManager(aoitest.Origin, 500, DefaultLooseFactor)
+	})
+}
+
+func newCubeOracle() aoi.AOIManager {
+	return aoitest.NewOracle(aoitest.InCube)
+}
+
+func TestDifferential(t *testing.T) {
+	aoitest.RunDifferential(t, func() aoi.AOIManager { return NewManager() }, newCubeOracle, 200, 300)
+}
+
+func FuzzDifferential(f *testing.F) {
+	f.Add([]byte{0, 1, 0, 0, 0, 3, 0, 2, 3, 0, 0, 0, 3, 9, 0, 0, 0, 0})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := aoitest.Diff(NewManager(), newCubeOracle(), aoitest.OpsFromBytes(data)); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestOctreeDifferential(t *testing.T) {
+	aoitest.RunDifferential(t, func() aoi.AOIManager {
+		return NewOctreeManager(aoitest.Origin, 8, DefaultLooseFactor)
+	}, newCubeOracle, 200, 300)
+}
+
+func TestHashConformance(t *testing.T) {
+	aoitest.RunConformance(t, func() aoi.AOIManager {
+		return NewHashManager(10)
+	})
+}
+
+func TestHashDifferential(t *testing.T) {
+	aoitest.RunDifferential(t, func() aoi.AOIManager {
+		return NewHashManager(3)
+	}, newCubeOracle, 200, 300)
+}
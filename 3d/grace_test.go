@@ -0,0 +1,46 @@
+package three_dim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/aoitest"
+)
+
+func TestLeaveGrace(t *testing.T) {
+	m := NewManager()
+	rec := aoitest.NewRecorder()
+	m.SetCallback(rec)
+	clock := aoi.NewManualClock(time.Unix(0, 0))
+	m.SetLeaveGrace(clock, 3*time.Second)
+	m.AddPlayer(1)
+	m.AddEntity(1, &aoi.Position{X: 50, Y: 50, Z: 50}, 20)
+	m.Subscribe(1, 1)
+	m.AddEntity(2, &aoi.Position{X: 60, Y: 50, Z: 50}, 0)
+	rec.Take()
+
+	m.MoveEntity(2, &aoi.Position{X: 80, Y: 50, Z: 50})
+	if !m.CanSee(1, 2) || !m.GetView(1).Contains(2) {
+		t.Fatal("target should stay visible during the grace period")
+	}
+	clock.Advance(2 * time.Second)
+	m.Tick()
+	m.MoveEntity(2, &aoi.Position{X: 60, Y: 50, Z: 50})
+	clock.Advance(5 * time.Second)
+	m.Tick()
+	if got := rec.Take(); len(got) != 0 {
+		t.Fatalf("a brief gap should be smoothed out, events %v", got)
+	}
+
+	// 实体被移除同样走宽限期
+	m.RemoveEntity(2)
+	if !m.CanSee(1, 2) {
+		t.Fatal("removed target should stay visible during the grace period")
+	}
+	clock.Advance(3 * time.Second)
+	m.Tick()
+	if got := rec.Take(); len(got) != 1 || got[0].Enter || m.CanSee(1, 2) {
+		t.Fatalf("expired leave should be delivered, events %v", got)
+	}
+}
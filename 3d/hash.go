@@ -0,0 +1,311 @@
+package three_dim
+
+import (
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/internal/maxtrack"
+	"github.com/beijian128/aoi/internal/players"
+	"github.com/beijian128/aoi/internal/sparse"
+	"github.com/beijian128/aoi/internal/view"
+)
+
+// hashCell 一个格子
+type hashCell struct {
+	key      sparse.Key
+	entities map[aoi.EntityID]*hashEntity
+}
+
+// hashEntity 空间哈希中的实体
+type hashEntity struct {
+	id       aoi.EntityID
+	pos      [3]aoi.Float
+	rangeVal aoi.Float // 立方体视野的半边长
+	cell     *hashCell
+
+	subscribers map[aoi.PlayerID]*aoi.Player
+
+	// visible 我当前能看见的实体
+	visible aoi.Set[*hashEntity]
+	// watchers 当前能看见我的实体 (visible 的反向索引)
+	watchers aoi.Set[*hashEntity]
+}
+
+// HashManager 基于均匀空间哈希的 AOI 管理器 (2D 网格的 3D 版本)
+// 实体按所在的 (cx, cy, cz) 挂在格子上，格子按需创建、空了就释放；
+// 静止的实体没有任何维护开销，适合体素/沙盒世界中数量巨大、很少移动的实体
+type HashManager struct {
+	cells    *sparse.Cells[hashCell]
+	entities map[aoi.EntityID]*hashEntity
+	players  map[aoi.PlayerID]*aoi.Player
+
+	// maxRange 所有实体中最大的视野半径
+	// 实体移动时，只有这个距离内的实体才可能看见它
+	maxRange maxtrack.Tracker
+
+	eventCallback aoi.AOICallback
+}
+
+// NewHashManager 创建空间哈希管理器，cellSize 建议与常见的视野半径相当
+func NewHashManager(cellSize aoi.Float) *HashManager {
+	return &HashManager{
+		cells:    sparse.New[hashCell](cellSize, 3),
+		entities: make(map[aoi.EntityID]*hashEntity),
+		players:  make(map[aoi.PlayerID]*aoi.Player),
+	}
+}
+
+func (m *HashManager) SetCallback(cb aoi.AOICallback) {
+	m.eventCallback = cb
+}
+
+// AddPlayer 注册玩家
+func (m *HashManager) AddPlayer(id aoi.PlayerID) {
+	if _, ok := m.players[id]; !ok {
+		m.players[id] = players.New(id)
+	}
+}
+
+// RemovePlayer 移除玩家 (例如客户端断线)，取消它对所有实体的订阅
+// emitLeave 为 true 时对视野内的所有目标触发 OnLeave
+func (m *HashManager) RemovePlayer(id aoi.PlayerID, emitLeave bool) {
+	players.Drop(m.players, m.entities, id, emitLeave, m.eventCallback, func(e *hashEntity) {
+		delete(e.subscribers, id)
+	})
+}
+
+// AddEntity 添加物理单位 (立方体视野，rangeVal 为半边长)
+func (m *HashManager) AddEntity(id aoi.EntityID, pos *aoi.Position, rangeVal aoi.Float) {
+	if pos == nil {
+		return
+	}
+	if _, ok := m.entities[id]; ok {
+		return
+	}
+	e := &hashEntity{
+		id:          id,
+		pos:         [3]aoi.Float{pos.X, pos.Y, pos.Z},
+		rangeVal:    max(rangeVal, 0),
+		subscribers: make(map[aoi.PlayerID]*aoi.Player),
+		visible:     aoi.NewSet[*hashEntity](),
+		watchers:    aoi.NewSet[*hashEntity](),
+	}
+	m.attach(e)
+	m.entities[id] = e
+	m.maxRange.Add(e.rangeVal)
+	m.refresh(e)
+}
+
+// RemoveEntity 移除物理单位
+func (m *HashManager) RemoveEntity(id aoi.EntityID) {
+	e, ok := m.entities[id]
+	if !ok {
+		return
+	}
+	m.detach(e)
+	delete(m.entities, id)
+	players.DropEntity(e.subscribers, id)
+	e.visible.ForEach(func(other *hashEntity) bool {
+		m.leave(e, other)
+		return false
+	})
+	e.watchers.ForEach(func(other *hashEntity) bool {
+		m.leave(other, e)
+		return false
+	})
+	if m.maxRange.Remove(e.rangeVal) {
+		m.resetMaxRange()
+	}
+}
+
+func (m *HashManager) MoveEntity(id aoi.EntityID, pos *aoi.Position) {
+	if pos == nil {
+		return
+	}
+	e, ok := m.entities[id]
+	if !ok {
+		return
+	}
+	e.pos = [3]aoi.Float{pos.X, pos.Y, pos.Z}
+	if key := m.keyOf(e.pos); key != e.cell.key {
+		m.detach(e)
+		m.attach(e)
+	}
+	// 即使没有跨格子，距离也可能发生了变化，需要重新判定
+	m.refresh(e)
+}
+
+// SetRange 修改视野半径，只会影响 e 作为观察者的视野
+func (m *HashManager) SetRange(id aoi.EntityID, r aoi.Float) {
+	e, ok := m.entities[id]
+	if !ok {
+		return
+	}
+	old := e.rangeVal
+	e.rangeVal = max(r, 0)
+	m.maxRange.Add(e.rangeVal)
+	if m.maxRange.Remove(old) {
+		m.resetMaxRange()
+	}
+	candidates := m.query(e.pos, e.rangeVal)
+	e.visible.ForEach(func(other *hashEntity) bool {
+		candidates.Add(other)
+		return false
+	})
+	candidates.ForEach(func(other *hashEntity) bool {
+		m.updatePair(e, other)
+		return false
+	})
+}
+
+// Subscribe 视野订阅
+func (m *HashManager) Subscribe(playerID aoi.PlayerID, entityID aoi.EntityID) {
+	p, pok := m.players[playerID]
+	e, eok := m.entities[entityID]
+	if !pok || !eok {
+		return
+	}
+	if _, exists := e.subscribers[playerID]; exists {
+		return
+	}
+	e.subscribers[playerID] = p
+	p.Subscriptions.Add(entityID)
+	e.visible.ForEach(func(target *hashEntity) bool {
+		view.Change(p, target.id, 1, m.eventCallback, nil)
+		return false
+	})
+}
+
+// Unsubscribe 取消订阅
+func (m *HashManager) Unsubscribe(playerID aoi.PlayerID, entityID aoi.EntityID) {
+	p, pok := m.players[playerID]
+	e, eok := m.entities[entityID]
+	if !pok || !eok {
+		return
+	}
+	if _, exists := e.subscribers[playerID]; !exists {
+		return
+	}
+	delete(e.subscribers, playerID)
+	p.Subscriptions.Remove(entityID)
+	e.visible.ForEach(func(target *hashEntity) bool {
+		view.Change(p, target.id, -1, m.eventCallback, nil)
+		return false
+	})
+}
+
+func (m *HashManager) GetView(id aoi.PlayerID) aoi.Set[aoi.EntityID] {
+	p, ok := m.players[id]
+	if !ok {
+		return nil
+	}
+	res := aoi.NewSet[aoi.EntityID]()
+	for tid := range p.FinalView {
+		res.Add(tid)
+	}
+	return res
+}
+
+func (m *HashManager) CanSee(watcherId aoi.PlayerID, targetId aoi.EntityID) bool {
+	watcher := m.players[watcherId]
+	if watcher == nil {
+		return false
+	}
+	return watcher.FinalView[targetId] > 0
+}
+
+// keyOf pos 所在格子的坐标
+func (m *HashManager) keyOf(pos [3]aoi.Float) sparse.Key {
+	return m.cells.KeyOf(aoi.Position{X: pos[0], Y: pos[1], Z: pos[2]})
+}
+
+// attach 把实体挂到所在的格子上，格子不存在时创建
+func (m *HashManager) attach(e *hashEntity) {
+	key := m.keyOf(e.pos)
+	c := m.cells.Get(key)
+	if c == nil {
+		c = &hashCell{key: key, entities: make(map[aoi.EntityID]*hashEntity)}
+		m.cells.Put(key, c)
+	}
+	c.entities[e.id] = e
+	e.cell = c
+}
+
+// detach 将实体从所在的格子摘下，格子空了就释放
+func (m *HashManager) detach(e *hashEntity) {
+	delete(e.cell.entities, e.id)
+	if len(e.cell.entities) == 0 {
+		m.cells.Release(e.cell.key)
+	}
+	e.cell = nil
+}
+
+// query 找出以 pos 为中心、半边长为 r 的立方体所覆盖的格子中的所有实体
+// 只是粗筛，调用方需要自己再做判定
+func (m *HashManager) query(pos [3]aoi.Float, r aoi.Float) aoi.Set[*hashEntity] {
+	set := aoi.NewSet[*hashEntity]()
+	m.cells.ForEachInBox(aoi.Position{X: pos[0], Y: pos[1], Z: pos[2]}, r, func(c *hashCell) {
+		for _, e := range c.entities {
+			set.Add(e)
+		}
+	})
+	return set
+}
+
+// refresh 重新判定 e 与周围实体之间 (双向) 的可见性
+// e 能看见的实体在 e 的视野半径内，能看见 e 的实体在 maxRange 内
+func (m *HashManager) refresh(e *hashEntity) {
+	candidates := m.query(e.pos, max(e.rangeVal, m.maxRange.Max()))
+	candidates.Remove(e)
+	// 原本有视野关系但已经跑出扫描范围的实体，也要参与判定
+	e.visible.ForEach(func(other *hashEntity) bool {
+		candidates.Add(other)
+		return false
+	})
+	e.watchers.ForEach(func(other *hashEntity) bool {
+		candidates.Add(other)
+		return false
+	})
+	candidates.ForEach(func(other *hashEntity) bool {
+		m.updatePair(e, other)
+		m.updatePair(other, e)
+		return false
+	})
+}
+
+// resetMaxRange 重新统计最大视野半径
+func (m *HashManager) resetMaxRange() {
+	m.maxRange.Reset()
+	for _, e := range m.entities {
+		m.maxRange.Add(e.rangeVal)
+	}
+}
+
+func (m *HashManager) updatePair(watcher, target *hashEntity) {
+	want := hashInRange(watcher, target)
+	has := watcher.visible.Contains(target)
+	if want && !has {
+		watcher.visible.Add(target)
+		target.watchers.Add(watcher)
+		view.Notify(watcher.subscribers, target.id, 1, m.eventCallback, nil)
+	} else if !want && has {
+		m.leave(watcher, target)
+	}
+}
+
+func (m *HashManager) leave(watcher, target *hashEntity) {
+	watcher.visible.Remove(target)
+	target.watchers.Remove(watcher)
+	view.Notify(watcher.subscribers, target.id, -1, m.eventCallback, nil)
+}
+
+// hashInRange target 是否处于 watcher 的立方体视野内 (与十字链表的判定一致，边界算在内)
+func hashInRange(watcher, target *hashEntity) bool {
+	if watcher == target {
+		return false
+	}
+	for axis := 0; axis < 3; axis++ {
+		if target.pos[axis] < watcher.pos[axis]-watcher.rangeVal || target.pos[axis] > watcher.pos[axis]+watcher.rangeVal {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package three_dim
+
+import (
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestHashCells(t *testing.T) {
+	m := NewHashManager(10)
+	m.AddPlayer(1)
+	// 跨越负坐标、远离原点
+	m.AddEntity(1, &aoi.Position{X: -1e9, Y: -5, Z: 3e9}, 15)
+	m.Subscribe(1, 1)
+	m.AddEntity(2, &aoi.Position{X: -1e9 + 12, Y: 5, Z: 3e9 - 12}, 0)
+	m.AddEntity(3, &aoi.Position{X: 2, Y: -2, Z: 0}, 0)
+	if !m.CanSee(1, 2) || m.CanSee(1, 3) || m.cells.Len() != 3 {
+		t.Fatalf("cells = %d", m.cells.Len())
+	}
+	// 格子随实体创建和释放
+	m.MoveEntity(2, &aoi.Position{X: -1e9 + 5, Y: -5, Z: 3e9})
+	m.RemoveEntity(3)
+	if !m.CanSee(1, 2) || m.cells.Len() != 1 {
+		t.Fatalf("empty cells should be freed, cells = %d", m.cells.Len())
+	}
+	// 视野半径远大于格子时遍历现有的格子
+	m.SetRange(1, 1e12)
+	m.AddEntity(3, &aoi.Position{X: 5e11, Y: 0, Z: 0}, 0)
+	if !m.CanSee(1, 3) {
+		t.Fatal("huge ranges should still find every target")
+	}
+	// 大视野的观察者离开之后，maxRange 回落
+	m.RemoveEntity(1)
+	if m.maxRange.Max() != 0 {
+		t.Fatalf("maxRange = %v", m.maxRange.Max())
+	}
+	m.RemoveEntity(2)
+	m.RemoveEntity(3)
+	if m.cells.Len() != 0 {
+		t.Fatalf("all cells should be freed, cells = %d", m.cells.Len())
+	}
+}
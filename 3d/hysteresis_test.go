@@ -0,0 +1,109 @@
+package three_dim
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/aoitest"
+)
+
+func TestLeaveMargin(t *testing.T) {
+	m := NewManager()
+	rec := aoitest.NewRecorder()
+	m.SetCallback(rec)
+	m.SetLeaveMargin(3)
+	m.AddPlayer(1)
+	m.AddEntity(1, &aoi.Position{X: 50, Y: 50, Z: 50}, 20)
+	m.Subscribe(1, 1)
+	m.AddEntity(2, &aoi.Position{X: 71, Y: 50, Z: 50}, 0)
+	if m.CanSee(1, 2) {
+		t.Fatal("entering still uses the plain range")
+	}
+	for i := 0; i < 10; i++ {
+		m.MoveEntity(2, &aoi.Position{X: 69, Y: 50, Z: 50})
+		m.MoveEntity(2, &aoi.Position{X: 72, Y: 50, Z: 50})
+	}
+	if got := rec.Take(); len(got) != 1 || !got[0].Enter {
+		t.Fatalf("walking along the edge should not flap, events %v", got)
+	}
+	m.MoveEntity(2, &aoi.Position{X: 74, Y: 50, Z: 50})
+	if m.CanSee(1, 2) {
+		t.Fatal("leaving beyond range+margin should leave")
+	}
+}
+
+// 目标已经在余量带内 (InBox 中)，扩大视野之后应该立即进入
+func TestLeaveMarginSetRange(t *testing.T) {
+	m := NewManager()
+	rec := aoitest.NewRecorder()
+	m.SetCallback(rec)
+	m.SetLeaveMargin(5)
+	m.AddPlayer(1)
+	m.AddEntity(1, &aoi.Position{}, 10)
+	m.Subscribe(1, 1)
+	m.AddEntity(2, &aoi.Position{X: 12}, 0)
+	if m.CanSee(1, 2) {
+		t.Fatal("target in the margin band should not be visible yet")
+	}
+	rec.Take()
+	m.SetRange(1, 15)
+	if got := rec.Take(); len(got) != 1 || !got[0].Enter || !m.CanSee(1, 2) {
+		t.Fatalf("growing the range over a target in the margin band should enter it, events %v", got)
+	}
+	m.SetRange(1, 10)
+	if !m.CanSee(1, 2) || len(rec.Take()) != 0 {
+		t.Fatal("shrinking the range within the margin should keep the target")
+	}
+	m.SetRange(1, 5)
+	if got := rec.Take(); len(got) != 1 || got[0].Enter {
+		t.Fatalf("shrinking beyond range+margin should leave, events %v", got)
+	}
+}
+
+// 随机游走：可见的目标一定在视野盒+余量内，视野盒内的目标一定可见，回调与引用计数保持一致
+func TestLeaveMarginRandomWalk(t *testing.T) {
+	const n, r, margin = 30, 10, 2
+	rnd := rand.New(rand.NewSource(1))
+	m := NewManager()
+	rec := aoitest.NewRecorder()
+	m.SetCallback(rec)
+	m.SetLeaveMargin(margin)
+	pos := make(map[aoi.EntityID]aoi.Position)
+	for i := aoi.EntityID(1); i <= n; i++ {
+		p := aoi.Position{X: aoi.Float(rnd.Intn(40)), Y: aoi.Float(rnd.Intn(40)), Z: aoi.Float(rnd.Intn(40))}
+		pos[i] = p
+		m.AddPlayer(aoi.PlayerID(i))
+		m.AddEntity(i, &p, r)
+		m.Subscribe(aoi.PlayerID(i), i)
+	}
+	for step := 0; step < 3000; step++ {
+		id := aoi.EntityID(rnd.Intn(n) + 1)
+		p := pos[id]
+		p.X += aoi.Float(rnd.Intn(5) - 2)
+		p.Y += aoi.Float(rnd.Intn(5) - 2)
+		p.Z += aoi.Float(rnd.Intn(5) - 2)
+		pos[id] = p
+		m.MoveEntity(id, &p)
+	}
+	if len(rec.Violations) > 0 {
+		t.Fatalf("callback contract violated: %v", rec.Violations)
+	}
+	for w := aoi.EntityID(1); w <= n; w++ {
+		if v := rec.View(aoi.PlayerID(w)); v.Size() != m.GetView(aoi.PlayerID(w)).Size() {
+			t.Fatalf("callbacks and GetView disagree for %d", w)
+		}
+		for tgt := aoi.EntityID(1); tgt <= n; tgt++ {
+			if w == tgt {
+				continue
+			}
+			got := m.CanSee(aoi.PlayerID(w), tgt)
+			if inBox(pos[w], aoi.CubeBox(r), pos[tgt]) && !got {
+				t.Fatalf("%d should see %d inside the range", w, tgt)
+			}
+			if !inBox(pos[w], aoi.CubeBox(r+margin), pos[tgt]) && got {
+				t.Fatalf("%d should not see %d beyond range+margin", w, tgt)
+			}
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package three_dim
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/beijian128/aoi"
+)
+
+const (
+	// indexStride 重建索引时每隔多少个节点抽样一次
+	indexStride = 16
+	// rebuildSteps 插入时沿链表走了这么多步，说明索引已经严重过期 (节点移动、删除)，需要重建
+	rebuildSteps = 1024
+)
+
+// EntitySpec BulkAdd 中的一个实体
+type EntitySpec struct {
+	ID  aoi.EntityID
+	Pos aoi.Position
+	Box aoi.Box
+}
+
+// BulkAdd 批量添加实体 (例如启动时加载大量 NPC)
+// 新节点排序之后与链表归并，再扫描一遍链表直接算出重叠计数，代价与实体数和实际重叠数成正比
+// 已经存在的 ID (包括批内重复的) 会被忽略
+func (m *Manager) BulkAdd(specs []EntitySpec) {
+	fresh := make(map[aoi.EntityID]*Entity, len(specs))
+	added := make([]*Entity, 0, len(specs))
+	for _, spec := range specs {
+		if _, ok := m.entities[spec.ID]; ok {
+			continue
+		}
+		e := newEntity(spec.ID, spec.Pos, spec.Box)
+		m.createMarkers(e)
+		m.entities[e.ID] = e
+		fresh[e.ID] = e
+		added = append(added, e)
+	}
+	if len(added) == 0 {
+		return
+	}
+
+	nodes := make([]*Marker, 0, 3*len(added))
+	for axis := 0; axis < 3; axis++ {
+		nodes = nodes[:0]
+		for _, e := range added {
+			nodes = append(nodes, e.Markers[axis][:]...)
+		}
+		slices.SortStableFunc(nodes, func(a, b *Marker) int {
+			if markerLess(a, b) {
+				return -1
+			}
+			if markerLess(b, a) {
+				return 1
+			}
+			return 0
+		})
+		m.axes[axis].merge(nodes)
+		m.sweep(axis, fresh)
+	}
+
+	for _, e := range added {
+		m.noteWidth(e)
+	}
+	for _, e := range added {
+		m.settleOverlaps(e, fresh)
+	}
+}
+
+// insert 把 node 插入到链表中的有序位置
+func (l *AxisList) insert(node *Marker) {
+	prev, steps := l.search(node)
+	l.linkAfter(prev, node)
+	if steps > rebuildSteps {
+		l.rebuildIndex()
+	} else if steps > indexStride {
+		// 这一段节点太密，把 node 也加入索引
+		l.addIndex(node)
+	}
+}
+
+// search 找到 node 应该插在哪个节点的后面，同时返回沿链表走了多少步
+// 坐标相同的节点中，新节点排在最后 (与从尾部冒泡过来的结果一致)
+func (l *AxisList) search(node *Marker) (*Marker, int) {
+	cur := l.Head
+	// 索引中最后一个不大于 node 的抽样节点
+	i := sort.Search(len(l.index), func(i int) bool { return markerLess(node, l.index[i]) }) - 1
+	for ; i >= 0; i-- {
+		if !l.index[i].detached {
+			cur = l.index[i]
+			break
+		}
+	}
+	// 抽样节点之后可能移动过，两个方向都要走
+	steps := 0
+	for cur.next != l.Tail && !markerLess(node, cur.next) {
+		cur = cur.next
+		steps++
+	}
+	for cur != l.Head && markerLess(node, cur) {
+		cur = cur.prev
+		steps++
+	}
+	return cur, steps
+}
+
+func (l *AxisList) linkAfter(prev, node *Marker) {
+	node.prev = prev
+	node.next = prev.next
+	prev.next.prev = node
+	prev.next = node
+}
+
+// unlink 把 node 从链表中断开
+func (l *AxisList) unlink(node *Marker) {
+	node.prev.next = node.next
+	node.next.prev = node.prev
+	node.detached = true
+	if node.indexed {
+		l.detached++
+		if l.detached*2 > len(l.index) {
+			l.rebuildIndex()
+		}
+	}
+}
+
+// merge 把已经排好序的 nodes 归并到链表中
+func (l *AxisList) merge(nodes []*Marker) {
+	cur := l.Head
+	for _, node := range nodes {
+		for cur.next != l.Tail && !markerLess(node, cur.next) {
+			cur = cur.next
+		}
+		l.linkAfter(cur, node)
+		cur = node
+	}
+	l.rebuildIndex()
+}
+
+func (l *AxisList) addIndex(node *Marker) {
+	i := sort.Search(len(l.index), func(i int) bool { return markerLess(node, l.index[i]) })
+	l.index = slices.Insert(l.index, i, node)
+	node.indexed = true
+}
+
+// rebuildIndex 按链表当前的顺序重新抽样
+func (l *AxisList) rebuildIndex() {
+	for _, node := range l.index {
+		node.indexed = false
+	}
+	l.index = l.index[:0]
+	l.detached = 0
+	i := 0
+	for node := l.Head.next; node != l.Tail; node = node.next {
+		if i%indexStride == 0 {
+			l.index = append(l.index, node)
+			node.indexed = true
+		}
+		i++
+	}
+}
+
+// countOverlaps 直接计算刚插入的 e 与其它实体在各轴上的重叠计数 (代替逐个穿越节点)
+func (m *Manager) countOverlaps(e *Entity) {
+	for axis := 0; axis < 3; axis++ {
+		// e 作为观察者: 自己 Min 与 Max 之间的 Pos 节点
+		end := e.Markers[axis][MarkerMax]
+		for node := e.Markers[axis][MarkerMin].next; node != end; node = node.next {
+			if node.Type == MarkerPos && node.Owner != e {
+				addCount(e, node.Owner)
+			}
+		}
+		// e 作为目标: 包含 Pos 的区间的 Min 节点一定在 Pos 左边 maxWidth 之内
+		pos := e.Markers[axis][MarkerPos]
+		head := m.axes[axis].Head
+		for node := pos.prev; node != head && pos.Val-node.Val <= m.maxWidth[axis]; node = node.prev {
+			if node.Type == MarkerMin && node.Owner != e && markerLess(pos, node.Owner.Markers[axis][MarkerMax]) {
+				addCount(node.Owner, e)
+			}
+		}
+	}
+	m.settleOverlaps(e, nil)
+}
+
+// sweep 扫描一遍 axis 轴的链表，累加至少有一方在 fresh 中的实体对的重叠计数
+func (m *Manager) sweep(axis int, fresh map[aoi.EntityID]*Entity) {
+	// 当前打开的区间 (已经过了 Min，还没到 Max)，按新旧分开
+	activeOld := make(map[*Entity]struct{})
+	activeNew := make(map[*Entity]struct{})
+	list := m.axes[axis]
+	for node := list.Head.next; node != list.Tail; node = node.next {
+		_, isNew := fresh[node.Owner.ID]
+		active := activeOld
+		if isNew {
+			active = activeNew
+		}
+		switch node.Type {
+		case MarkerMin:
+			active[node.Owner] = struct{}{}
+		case MarkerMax:
+			delete(active, node.Owner)
+		case MarkerPos:
+			for watcher := range activeNew {
+				if watcher != node.Owner {
+					addCount(watcher, node.Owner)
+				}
+			}
+			if isNew {
+				for watcher := range activeOld {
+					addCount(watcher, node.Owner)
+				}
+			}
+		}
+	}
+}
+
+// settleOverlaps 计数完成之后，三个轴都重叠的实体对进入视野盒并做精确判定
+// 观察者也在 fresh 中的实体对由观察者自己处理，避免重复
+func (m *Manager) settleOverlaps(e *Entity, fresh map[aoi.EntityID]*Entity) {
+	for tid, c := range e.ViewCounts {
+		if c == 3 {
+			target := m.entities[tid]
+			e.InBox[tid] = target
+			target.Watchers[e.ID] = e
+			m.updateVisible(e, target)
+		}
+	}
+	for wid, watcher := range e.CountedBy {
+		if _, ok := fresh[wid]; ok {
+			continue
+		}
+		if watcher.ViewCounts[e.ID] == 3 {
+			watcher.InBox[e.ID] = e
+			e.Watchers[wid] = watcher
+			m.updateVisible(watcher, e)
+		}
+	}
+}
+
+func addCount(watcher, target *Entity) {
+	watcher.ViewCounts[target.ID]++
+	target.CountedBy[watcher.ID] = watcher
+}
+
+// noteWidth 用 e 当前的区间宽度更新 maxWidth
+// 宽度按节点的实际坐标计算，与 countOverlaps 中的比较方式一致，不受浮点误差影响
+func (m *Manager) noteWidth(e *Entity) {
+	for axis := 0; axis < 3; axis++ {
+		w := e.Markers[axis][MarkerMax].Val - e.Markers[axis][MarkerMin].Val
+		if w > m.maxWidth[axis] {
+			m.maxWidth[axis] = w
+		}
+	}
+}
+
+// shrinkWidth 移除 (removed 不为 nil) 或移动实体之后调用
+// 只有移除了最宽的实体才会让 maxWidth 偏大，之后按均摊的节奏重新统计，而不是每次移除都遍历所有实体
+func (m *Manager) shrinkWidth(removed *Entity) {
+	if removed != nil && !m.widthStale {
+		for axis := 0; axis < 3; axis++ {
+			if removed.Markers[axis][MarkerMax].Val-removed.Markers[axis][MarkerMin].Val >= m.maxWidth[axis] {
+				m.widthStale = true
+			}
+		}
+	}
+	if !m.widthStale {
+		return
+	}
+	m.widthOps++
+	if m.widthOps >= len(m.entities) {
+		m.resetMaxWidth()
+	}
+}
+
+// resetMaxWidth 重新统计 maxWidth
+func (m *Manager) resetMaxWidth() {
+	m.maxWidth = [3]aoi.Float{}
+	m.widthStale, m.widthOps = false, 0
+	for _, e := range m.entities {
+		m.noteWidth(e)
+	}
+}
@@ -0,0 +1,118 @@
+package three_dim
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/aoitest"
+)
+
+// 逐个添加与批量添加 (分两批，第二批加入时已经有订阅者) 的结果都必须与暴力判定一致
+func TestBulkAdd(t *testing.T) {
+	const n = 300
+	rnd := rand.New(rand.NewSource(1))
+	specs := make([]EntitySpec, n)
+	for i := range specs {
+		c := func() aoi.Float { return aoi.Float(rnd.Intn(41) - 20) }
+		specs[i] = EntitySpec{
+			ID:  aoi.EntityID(i + 1),
+			Pos: aoi.Position{X: aoi.Float(rnd.Intn(200)), Y: aoi.Float(rnd.Intn(200)), Z: aoi.Float(rnd.Intn(200))},
+			Box: aoi.Box{Min: aoi.Position{X: c(), Y: c(), Z: c()}, Max: aoi.Position{X: c(), Y: c(), Z: c()}},
+		}
+	}
+
+	one := NewManager()
+	for _, s := range specs {
+		one.AddEntityBox(s.ID, &s.Pos, s.Box)
+	}
+	bulk := NewManager()
+	rec := aoitest.NewRecorder()
+	bulk.SetCallback(rec)
+	bulk.BulkAdd(specs[:n/2])
+	for i := aoi.EntityID(1); i <= n/2; i++ {
+		bulk.AddPlayer(aoi.PlayerID(i))
+		bulk.Subscribe(aoi.PlayerID(i), i)
+	}
+	rec.Take()
+	bulk.BulkAdd(specs[n/2:])
+
+	// 第二批只产生真正的 Enter
+	for _, ev := range rec.Take() {
+		w, tgt := specs[ev.Player-1], specs[ev.Target-1]
+		if !ev.Enter || tgt.ID <= n/2 || !inBox(w.Pos, w.Box, tgt.Pos) {
+			t.Fatalf("unexpected event %v", ev)
+		}
+	}
+	for _, m := range []*Manager{one, bulk} {
+		checkSorted(t, m)
+		for _, w := range specs {
+			for _, tgt := range specs {
+				want := w.ID != tgt.ID && inBox(w.Pos, w.Box, tgt.Pos)
+				if got := m.entities[w.ID].VisibleSet[tgt.ID]; got != want {
+					t.Fatalf("visible(%d, %d) = %v, want %v", w.ID, tgt.ID, got, want)
+				}
+			}
+		}
+	}
+
+	// 之后的移动、删除照常工作
+	for step := 0; step < 1000; step++ {
+		s := &specs[rnd.Intn(n)]
+		s.Pos = aoi.Position{X: aoi.Float(rnd.Intn(200)), Y: aoi.Float(rnd.Intn(200)), Z: aoi.Float(rnd.Intn(200))}
+		bulk.MoveEntity(s.ID, &s.Pos)
+	}
+	for i := 0; i < n; i += 3 {
+		bulk.RemoveEntity(specs[i].ID)
+	}
+	for i := 0; i < n; i += 3 {
+		bulk.AddEntityBox(specs[i].ID, &specs[i].Pos, specs[i].Box)
+	}
+	checkSorted(t, bulk)
+	for _, w := range specs {
+		for _, tgt := range specs {
+			want := w.ID != tgt.ID && inBox(w.Pos, w.Box, tgt.Pos)
+			if got := bulk.entities[w.ID].VisibleSet[tgt.ID]; got != want {
+				t.Fatalf("after moves: visible(%d, %d) = %v, want %v", w.ID, tgt.ID, got, want)
+			}
+		}
+	}
+}
+
+// 新实体直接插入到有序位置，不会在途中与其它实体产生 Enter/Leave
+func TestInsertNoTransientEvents(t *testing.T) {
+	m := NewManager()
+	rec := aoitest.NewRecorder()
+	m.SetCallback(rec)
+	for i := aoi.EntityID(1); i <= 50; i++ {
+		m.AddPlayer(aoi.PlayerID(i))
+		m.AddEntity(i, &aoi.Position{X: aoi.Float(i) * 100}, 10)
+		m.Subscribe(aoi.PlayerID(i), i)
+	}
+	rec.Take()
+
+	m.AddEntity(100, &aoi.Position{X: -50}, 10)
+	m.AddEntity(101, &aoi.Position{X: 2505}, 0)
+	got := rec.Take()
+	if len(got) != 1 || got[0] != (aoitest.Event{Player: 25, Target: 101, Enter: true}) {
+		t.Fatalf("events = %v", got)
+	}
+	checkSorted(t, m)
+}
+
+// checkSorted 三个轴的链表都必须有序，且每个实体的节点都在链表中
+func checkSorted(t *testing.T, m *Manager) {
+	t.Helper()
+	for axis, list := range m.axes {
+		count := 0
+		for node := list.Head.next; node != list.Tail; node = node.next {
+			if node.prev != list.Head && markerLess(node, node.prev) {
+				t.Fatalf("axis %d is not sorted at %v", axis, node.Val)
+			}
+			count++
+		}
+		if count != 3*len(m.entities) {
+			t.Fatalf("axis %d has %d markers, want %d", axis, count, 3*len(m.entities))
+		}
+	}
+}
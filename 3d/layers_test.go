@@ -0,0 +1,45 @@
+package three_dim
+
+import (
+	"testing"
+
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/aoitest"
+)
+
+func TestLayers(t *testing.T) {
+	const (
+		layerLiving aoi.LayerMask = 1 << iota
+		layerGhost
+	)
+	m := NewManager()
+	rec := aoitest.NewRecorder()
+	m.SetCallback(rec)
+	m.AddPlayer(1)
+	m.AddEntity(1, &aoi.Position{X: 50, Y: 50, Z: 50}, 20)
+	m.Subscribe(1, 1)
+	m.SetLayers(1, layerLiving, layerLiving)
+	m.AddEntity(2, &aoi.Position{X: 55, Y: 50, Z: 50}, 20)
+	m.SetLayers(2, layerGhost, aoi.LayerAll)
+	m.AddEntity(3, &aoi.Position{X: 45, Y: 50, Z: 50}, 20)
+	if m.CanSee(1, 2) || !m.CanSee(1, 3) {
+		t.Fatalf("living player should only see the living, view %v", m.GetView(1))
+	}
+	rec.Take()
+
+	// 死亡之后能看见幽灵，同时 GM 隐身
+	m.SetLayers(1, layerLiving, layerLiving|layerGhost)
+	m.SetLayers(3, 0, aoi.LayerAll)
+	want := []aoitest.Event{{Enter: true, Player: 1, Target: 2}, {Enter: false, Player: 1, Target: 3}}
+	if got := rec.Take(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+
+	// 掩码在移动时同样生效
+	m.MoveEntity(3, &aoi.Position{X: 51, Y: 50, Z: 50})
+	m.MoveEntity(2, &aoi.Position{X: 100, Y: 50, Z: 50})
+	m.MoveEntity(2, &aoi.Position{X: 52, Y: 50, Z: 50})
+	if m.CanSee(1, 3) || !m.CanSee(1, 2) {
+		t.Fatalf("masks should survive moves, view %v", m.GetView(1))
+	}
+}
@@ -0,0 +1,35 @@
+package three_dim
+
+import (
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestOcclusion(t *testing.T) {
+	m := NewManager()
+	m.AddPlayer(1)
+	m.AddEntity(1, &aoi.Position{X: 40, Y: 1, Z: 50}, 30)
+	m.Subscribe(1, 1)
+	m.AddEntity(2, &aoi.Position{X: 60, Y: 1, Z: 50}, 30)
+
+	m.SetOccluder(aoi.NewObstacleLayer(aoi.Box3D{Min: aoi.Position{X: 49, Y: 0, Z: 0}, Max: aoi.Position{X: 51, Y: 5, Z: 100}}))
+	if m.CanSee(1, 2) {
+		t.Fatal("wall between the entities should block the view")
+	}
+
+	// 在视野盒内移动，越过墙顶
+	m.MoveEntity(2, &aoi.Position{X: 60, Y: 20, Z: 50})
+	if !m.CanSee(1, 2) {
+		t.Fatal("line of sight over the wall should be clear")
+	}
+	m.MoveEntity(2, &aoi.Position{X: 60, Y: 4, Z: 50})
+	if m.CanSee(1, 2) {
+		t.Fatal("target dropping behind the wall should leave again")
+	}
+
+	m.RemoveEntity(2)
+	if len(m.entities[1].los) != 0 {
+		t.Fatal("line of sight cache should be dropped together with the pair")
+	}
+}
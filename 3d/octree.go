@@ -0,0 +1,426 @@
+package three_dim
+
+import (
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/internal/players"
+	"github.com/beijian128/aoi/internal/view"
+)
+
+const (
+	// octreeMaxDepth 八叉树最大深度
+	octreeMaxDepth = 12
+	// DefaultLooseFactor 默认松散系数，节点松散边界为普通边界的 2 倍
+	DefaultLooseFactor aoi.Float = 2
+)
+
+// octEntity 八叉树中的实体
+// 以视野盒 [pos+rangeMin, pos+rangeMax] 与 pos 的并集作为包围盒挂在八叉树节点上
+type octEntity struct {
+	id                 aoi.EntityID
+	pos                [3]aoi.Float
+	rangeMin, rangeMax [3]aoi.Float // 视野盒相对 pos 的偏移
+	node               *octNode
+
+	subscribers map[aoi.PlayerID]*aoi.Player
+
+	// visible 我当前能看见的实体
+	visible aoi.Set[*octEntity]
+	// watchers 当前能看见我的实体 (visible 的反向索引)
+	watchers aoi.Set[*octEntity]
+}
+
+// octNode 松散八叉树节点
+// 节点的松散边界为 center ± half*looseFactor，实体的包围盒必须完整落在所在节点的松散边界内
+// (根节点除外：放不进根节点的实体也挂在根节点上)
+type octNode struct {
+	parent   *octNode
+	index    int // 在父节点 children 中的下标
+	depth    int
+	center   [3]aoi.Float
+	half     aoi.Float
+	children [8]*octNode
+	entities map[aoi.EntityID]*octEntity
+	count    int // 子树中的实体数量
+}
+
+func newOctNode(parent *octNode, index int, center [3]aoi.Float, half aoi.Float) *octNode {
+	n := &octNode{
+		parent:   parent,
+		index:    index,
+		center:   center,
+		half:     half,
+		entities: make(map[aoi.EntityID]*octEntity),
+	}
+	if parent != nil {
+		n.depth = parent.depth + 1
+	}
+	return n
+}
+
+// fits 包围盒 [min,max] 是否完整落在节点的松散边界内
+func (n *octNode) fits(min, max [3]aoi.Float, looseFactor aoi.Float) bool {
+	loose := n.half * looseFactor
+	for axis := 0; axis < 3; axis++ {
+		if min[axis] < n.center[axis]-loose || max[axis] > n.center[axis]+loose {
+			return false
+		}
+	}
+	return true
+}
+
+// intersects 节点松散边界是否与 [min,max] 相交
+func (n *octNode) intersects(min, max [3]aoi.Float, looseFactor aoi.Float) bool {
+	loose := n.half * looseFactor
+	for axis := 0; axis < 3; axis++ {
+		if max[axis] < n.center[axis]-loose || min[axis] > n.center[axis]+loose {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *octNode) childIndex(pos [3]aoi.Float) int {
+	idx := 0
+	for axis := 0; axis < 3; axis++ {
+		if pos[axis] >= n.center[axis] {
+			idx |= 1 << axis
+		}
+	}
+	return idx
+}
+
+// childBounds 下标为 idx 的子节点的中心与半边长
+func (n *octNode) childBounds(idx int) ([3]aoi.Float, aoi.Float) {
+	half := n.half / 2
+	center := n.center
+	for axis := 0; axis < 3; axis++ {
+		if idx&(1<<axis) != 0 {
+			center[axis] += half
+		} else {
+			center[axis] -= half
+		}
+	}
+	return center, half
+}
+
+// child 获取 (必要时创建) 下标为 idx 的子节点
+func (n *octNode) child(idx int) *octNode {
+	if c := n.children[idx]; c != nil {
+		return c
+	}
+	center, half := n.childBounds(idx)
+	c := newOctNode(n, idx, center, half)
+	n.children[idx] = c
+	return c
+}
+
+// OctreeManager 基于松散八叉树的 AOI 管理器
+// 移动的开销只与树深和附近实体数有关，与移动距离无关，适合高速、长距离移动的场景
+type OctreeManager struct {
+	root          *octNode
+	looseFactor   aoi.Float
+	entities      map[aoi.EntityID]*octEntity
+	players       map[aoi.PlayerID]*aoi.Player
+	eventCallback aoi.AOICallback
+}
+
+// NewOctreeManager 创建八叉树管理器
+// center/halfSize 描述根节点覆盖的立方体，超出范围的实体挂在根节点上，依旧能正确工作
+// looseFactor 松散系数 (>=1)，越大实体越不容易因移动而换节点，但查询时需要检查的实体越多
+func NewOctreeManager(center aoi.Position, halfSize, looseFactor aoi.Float) *OctreeManager {
+	if looseFactor < 1 {
+		looseFactor = 1
+	}
+	return &OctreeManager{
+		root:        newOctNode(nil, 0, [3]aoi.Float{center.X, center.Y, center.Z}, halfSize),
+		looseFactor: looseFactor,
+		entities:    make(map[aoi.EntityID]*octEntity),
+		players:     make(map[aoi.PlayerID]*aoi.Player),
+	}
+}
+
+func (m *OctreeManager) SetCallback(cb aoi.AOICallback) {
+	m.eventCallback = cb
+}
+
+// AddPlayer 注册玩家
+func (m *OctreeManager) AddPlayer(id aoi.PlayerID) {
+	if _, ok := m.players[id]; !ok {
+		m.players[id] = players.New(id)
+	}
+}
+
+// RemovePlayer 移除玩家 (例如客户端断线)，取消它对所有实体的订阅
+// emitLeave 为 true 时对视野内的所有目标触发 OnLeave
+func (m *OctreeManager) RemovePlayer(id aoi.PlayerID, emitLeave bool) {
+	players.Drop(m.players, m.entities, id, emitLeave, m.eventCallback, func(e *octEntity) {
+		delete(e.subscribers, id)
+	})
+}
+
+// AddEntity 添加物理单位 (立方体视野，rangeVal 为半边长)
+func (m *OctreeManager) AddEntity(id aoi.EntityID, pos *aoi.Position, rangeVal aoi.Float) {
+	m.AddEntityBox(id, pos, aoi.CubeBox(rangeVal))
+}
+
+// AddEntityBox 以长方体视野添加物理单位
+func (m *OctreeManager) AddEntityBox(id aoi.EntityID, pos *aoi.Position, box aoi.Box) {
+	if pos == nil {
+		return
+	}
+	if _, ok := m.entities[id]; ok {
+		return
+	}
+	e := &octEntity{
+		id:          id,
+		pos:         [3]aoi.Float{pos.X, pos.Y, pos.Z},
+		subscribers: make(map[aoi.PlayerID]*aoi.Player),
+		visible:     aoi.NewSet[*octEntity](),
+		watchers:    aoi.NewSet[*octEntity](),
+	}
+	e.rangeMin, e.rangeMax = boxOffsets(box)
+	m.insert(e)
+	m.entities[id] = e
+	m.refresh(e)
+}
+
+// RemoveEntity 移除物理单位
+func (m *OctreeManager) RemoveEntity(id aoi.EntityID) {
+	e, ok := m.entities[id]
+	if !ok {
+		return
+	}
+	m.detach(e)
+	delete(m.entities, id)
+	players.DropEntity(e.subscribers, id)
+	e.visible.ForEach(func(other *octEntity) bool {
+		m.leave(e, other)
+		return false
+	})
+	e.watchers.ForEach(func(other *octEntity) bool {
+		m.leave(other, e)
+		return false
+	})
+}
+
+func (m *OctreeManager) MoveEntity(id aoi.EntityID, pos *aoi.Position) {
+	if pos == nil {
+		return
+	}
+	e, ok := m.entities[id]
+	if !ok {
+		return
+	}
+	e.pos = [3]aoi.Float{pos.X, pos.Y, pos.Z}
+	// 仍在原节点的松散边界内就不用换节点，这正是松散八叉树的意义
+	if min, max := e.bounds(); e.node == m.root || !e.node.fits(min, max, m.looseFactor) {
+		m.detach(e)
+		m.insert(e)
+	}
+	m.refresh(e)
+}
+
+// SetRange 修改视野半径 (立方体视野)
+func (m *OctreeManager) SetRange(id aoi.EntityID, r aoi.Float) {
+	if r < 0 {
+		r = 0
+	}
+	m.SetBox(id, aoi.CubeBox(r))
+}
+
+// SetBox 修改视野盒，包围盒放不进原节点时重新挂载
+func (m *OctreeManager) SetBox(id aoi.EntityID, box aoi.Box) {
+	e, ok := m.entities[id]
+	if !ok {
+		return
+	}
+	e.rangeMin, e.rangeMax = boxOffsets(box)
+	if min, max := e.bounds(); e.node == m.root || !e.node.fits(min, max, m.looseFactor) {
+		m.detach(e)
+		m.insert(e)
+	}
+	m.refresh(e)
+}
+
+// Subscribe 视野订阅
+func (m *OctreeManager) Subscribe(playerID aoi.PlayerID, entityID aoi.EntityID) {
+	p, pok := m.players[playerID]
+	e, eok := m.entities[entityID]
+	if !pok || !eok {
+		return
+	}
+	if _, exists := e.subscribers[playerID]; exists {
+		return
+	}
+	e.subscribers[playerID] = p
+	p.Subscriptions.Add(entityID)
+	e.visible.ForEach(func(target *octEntity) bool {
+		view.Change(p, target.id, 1, m.eventCallback, nil)
+		return false
+	})
+}
+
+// Unsubscribe 取消订阅
+func (m *OctreeManager) Unsubscribe(playerID aoi.PlayerID, entityID aoi.EntityID) {
+	p, pok := m.players[playerID]
+	e, eok := m.entities[entityID]
+	if !pok || !eok {
+		return
+	}
+	if _, exists := e.subscribers[playerID]; !exists {
+		return
+	}
+	delete(e.subscribers, playerID)
+	p.Subscriptions.Remove(entityID)
+	e.visible.ForEach(func(target *octEntity) bool {
+		view.Change(p, target.id, -1, m.eventCallback, nil)
+		return false
+	})
+}
+
+func (m *OctreeManager) GetView(id aoi.PlayerID) aoi.Set[aoi.EntityID] {
+	p, ok := m.players[id]
+	if !ok {
+		return nil
+	}
+	res := aoi.NewSet[aoi.EntityID]()
+	for tid := range p.FinalView {
+		res.Add(tid)
+	}
+	return res
+}
+
+func (m *OctreeManager) CanSee(watcherId aoi.PlayerID, targetId aoi.EntityID) bool {
+	watcher := m.players[watcherId]
+	if watcher == nil {
+		return false
+	}
+	return watcher.FinalView[targetId] > 0
+}
+
+// bounds 实体的包围盒：视野盒与自身位置的并集
+// 包含自身位置是为了让 refresh 的一次查询同时找到能看见 e 的实体
+func (e *octEntity) bounds() (min, max [3]aoi.Float) {
+	for axis := 0; axis < 3; axis++ {
+		min[axis] = e.pos[axis] + e.rangeMin[axis]
+		max[axis] = e.pos[axis] + e.rangeMax[axis]
+		if min[axis] > e.pos[axis] {
+			min[axis] = e.pos[axis]
+		}
+		if max[axis] < e.pos[axis] {
+			max[axis] = e.pos[axis]
+		}
+	}
+	return min, max
+}
+
+// insert 自顶向下找到能完整容纳实体包围盒的最深节点
+func (m *OctreeManager) insert(e *octEntity) {
+	n := m.root
+	min, max := e.bounds()
+	if n.fits(min, max, m.looseFactor) {
+		for n.depth < octreeMaxDepth {
+			idx := n.childIndex(e.pos)
+			// 先用子节点的边界做判定，避免创建用不上的节点
+			center, half := n.childBounds(idx)
+			probe := octNode{center: center, half: half}
+			if !probe.fits(min, max, m.looseFactor) {
+				break
+			}
+			n = n.child(idx)
+		}
+	}
+	n.entities[e.id] = e
+	e.node = n
+	for p := n; p != nil; p = p.parent {
+		p.count++
+	}
+}
+
+// detach 将实体从所在节点摘下，并回收空节点
+func (m *OctreeManager) detach(e *octEntity) {
+	n := e.node
+	delete(n.entities, e.id)
+	e.node = nil
+	for p := n; p != nil; p = p.parent {
+		p.count--
+	}
+	for n != m.root && n.count == 0 {
+		n.parent.children[n.index] = nil
+		n = n.parent
+	}
+}
+
+// query 遍历松散边界与 [min,max] 相交的节点中的所有实体 (根节点上的实体总是会被遍历)
+func (m *OctreeManager) query(n *octNode, min, max [3]aoi.Float, fn func(e *octEntity)) {
+	if n.count == 0 {
+		return
+	}
+	if n != m.root && !n.intersects(min, max, m.looseFactor) {
+		return
+	}
+	for _, e := range n.entities {
+		fn(e)
+	}
+	for _, c := range n.children {
+		if c != nil {
+			m.query(c, min, max, fn)
+		}
+	}
+}
+
+// refresh 重新判定 e 与周围实体之间 (双向) 的可见性
+// e 的包围盒包含了 e 自身的位置，因此用它做一次查询就能同时找到 e 能看见的和能看见 e 的实体
+func (m *OctreeManager) refresh(e *octEntity) {
+	min, max := e.bounds()
+	candidates := aoi.NewSet[*octEntity]()
+	m.query(m.root, min, max, func(other *octEntity) {
+		if other != e {
+			candidates.Add(other)
+		}
+	})
+	e.visible.ForEach(func(other *octEntity) bool {
+		candidates.Add(other)
+		return false
+	})
+	e.watchers.ForEach(func(other *octEntity) bool {
+		candidates.Add(other)
+		return false
+	})
+	candidates.ForEach(func(other *octEntity) bool {
+		m.updatePair(e, other)
+		m.updatePair(other, e)
+		return false
+	})
+}
+
+func (m *OctreeManager) updatePair(watcher, target *octEntity) {
+	want := octInRange(watcher, target)
+	has := watcher.visible.Contains(target)
+	if want && !has {
+		watcher.visible.Add(target)
+		target.watchers.Add(watcher)
+		view.Notify(watcher.subscribers, target.id, 1, m.eventCallback, nil)
+	} else if !want && has {
+		m.leave(watcher, target)
+	}
+}
+
+func (m *OctreeManager) leave(watcher, target *octEntity) {
+	watcher.visible.Remove(target)
+	target.watchers.Remove(watcher)
+	view.Notify(watcher.subscribers, target.id, -1, m.eventCallback, nil)
+}
+
+// octInRange target 是否处于 watcher 的视野盒内 (与十字链表的判定一致，边界算在内)
+func octInRange(watcher, target *octEntity) bool {
+	if watcher == target {
+		return false
+	}
+	for axis := 0; axis < 3; axis++ {
+		if target.pos[axis] < watcher.pos[axis]+watcher.rangeMin[axis] || target.pos[axis] > watcher.pos[axis]+watcher.rangeMax[axis] {
+			return false
+		}
+	}
+	return true
+}
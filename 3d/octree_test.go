@@ -0,0 +1,45 @@
+package three_dim
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestOctreeVisibilityMatchesCube(t *testing.T) {
+	for _, loose := range []aoi.Float{1, DefaultLooseFactor, 4} {
+		rnd := rand.New(rand.NewSource(1))
+		m := NewOctreeManager(aoi.Position{X: 250, Y: 250, Z: 250}, 250, loose)
+		randPos := func() *aoi.Position {
+			// 故意让一部分实体落在根节点之外
+			return &aoi.Position{
+				X: aoi.Float(rnd.Float64()*700 - 100),
+				Y: aoi.Float(rnd.Float64()*700 - 100),
+				Z: aoi.Float(rnd.Float64()*700 - 100),
+			}
+		}
+		for i := 0; i < 200; i++ {
+			m.AddEntity(aoi.EntityID(i), randPos(), aoi.Float(rnd.Float64()*120))
+		}
+		for step := 0; step < 3000; step++ {
+			id := aoi.EntityID(rnd.Intn(200))
+			if rnd.Intn(10) == 0 {
+				m.RemoveEntity(id)
+				m.AddEntity(id, randPos(), aoi.Float(rnd.Float64()*120))
+				continue
+			}
+			m.MoveEntity(id, randPos())
+		}
+		for _, w := range m.entities {
+			for _, tgt := range m.entities {
+				if octInRange(w, tgt) != w.visible.Contains(tgt) {
+					t.Fatalf("loose=%v: visibility of %d -> %d out of sync", loose, w.id, tgt.id)
+				}
+			}
+		}
+		if m.root.count != len(m.entities) {
+			t.Fatalf("loose=%v: root count = %d, want %d", loose, m.root.count, len(m.entities))
+		}
+	}
+}
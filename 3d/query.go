@@ -0,0 +1,108 @@
+package three_dim
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/beijian128/aoi"
+)
+
+var _ aoi.SpatialQuery = (*Manager)(nil)
+
+// QueryRadius 与 center 的距离不超过 r 的所有实体 (先按外接立方体在链表上粗筛)
+func (m *Manager) QueryRadius(center aoi.Position, r aoi.Float) []aoi.EntityID {
+	c := [3]aoi.Float{center.X, center.Y, center.Z}
+	var ids []aoi.EntityID
+	m.forEachInBox([3]aoi.Float{c[0] - r, c[1] - r, c[2] - r}, [3]aoi.Float{c[0] + r, c[1] + r, c[2] + r}, func(e *Entity) {
+		if distSq(c, e.Pos) <= r*r {
+			ids = append(ids, e.ID)
+		}
+	})
+	slices.Sort(ids)
+	return ids
+}
+
+// QueryBox 落在 box 中的所有实体
+func (m *Manager) QueryBox(box aoi.Box) []aoi.EntityID {
+	lo, hi := boxOffsets(box)
+	var ids []aoi.EntityID
+	m.forEachInBox(lo, hi, func(e *Entity) {
+		ids = append(ids, e.ID)
+	})
+	slices.Sort(ids)
+	return ids
+}
+
+// nearestMaxSteps QueryNearest 最多倍增扫描半径的次数，之后直接检查所有位置有效的实体
+// (位置为 NaN/Inf 的实体永远不会落在扫描半径内，有这样的实体时倍增凑不够 k 个)
+const nearestMaxSteps = 64
+
+// QueryNearest 离 center 最近的 k 个实体
+// 扫描半径从 1 开始倍增，直到半径内的实体足够 k 个 (半径外的实体一定更远)
+func (m *Manager) QueryNearest(center aoi.Position, k int) []aoi.EntityID {
+	// 中心不是有限的坐标时，所有距离都是 NaN 或无穷大，倍增半径永远凑不够 k 个
+	if k <= 0 || !center.X.IsFinite() || !center.Y.IsFinite() || !center.Z.IsFinite() {
+		return nil
+	}
+	c := [3]aoi.Float{center.X, center.Y, center.Z}
+	var found []*Entity
+	for step, r := 0, aoi.Float(1); ; step, r = step+1, r*2 {
+		found = found[:0]
+		if step == nearestMaxSteps {
+			for _, e := range m.entities {
+				if e.Pos[0].IsFinite() && e.Pos[1].IsFinite() && e.Pos[2].IsFinite() {
+					found = append(found, e)
+				}
+			}
+			break
+		}
+		m.forEachInBox([3]aoi.Float{c[0] - r, c[1] - r, c[2] - r}, [3]aoi.Float{c[0] + r, c[1] + r, c[2] + r}, func(e *Entity) {
+			if distSq(c, e.Pos) <= r*r {
+				found = append(found, e)
+			}
+		})
+		if len(found) >= k || len(found) == len(m.entities) {
+			break
+		}
+	}
+	slices.SortFunc(found, func(a, b *Entity) int {
+		if d := cmp.Compare(distSq(c, a.Pos), distSq(c, b.Pos)); d != 0 {
+			return d
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	ids := make([]aoi.EntityID, 0, min(k, len(found)))
+	for _, e := range found[:min(k, len(found))] {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+// forEachInBox 遍历位置落在 [lo, hi] 中的实体
+// 在盒子最窄的那个轴上，从 lo 处开始沿链表走到 hi，只检查这一段中的 Pos 节点
+func (m *Manager) forEachInBox(lo, hi [3]aoi.Float, fn func(e *Entity)) {
+	axis := 0
+	for a := 1; a < 3; a++ {
+		if hi[a]-lo[a] < hi[axis]-lo[axis] {
+			axis = a
+		}
+	}
+	list := m.axes[axis]
+	// 以坐标为 lo 的 Min 节点作为探针，它的插入位置之后就是第一个坐标不小于 lo 的 Pos 节点
+	prev, _ := list.search(&Marker{Type: MarkerMin, Val: lo[axis]})
+	for node := prev.next; node != list.Tail && node.Val <= hi[axis]; node = node.next {
+		if node.Type != MarkerPos {
+			continue
+		}
+		e := node.Owner
+		if e.Pos[0] >= lo[0] && e.Pos[0] <= hi[0] && e.Pos[1] >= lo[1] && e.Pos[1] <= hi[1] && e.Pos[2] >= lo[2] && e.Pos[2] <= hi[2] {
+			fn(e)
+		}
+	}
+}
+
+// distSq 距离的平方
+func distSq(a, b [3]aoi.Float) aoi.Float {
+	dx, dy, dz := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dx*dx + dy*dy + dz*dz
+}
@@ -0,0 +1,101 @@
+package three_dim
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/aoitest"
+)
+
+func TestSpatialQuery(t *testing.T) {
+	const n = 300
+	rnd := rand.New(rand.NewSource(1))
+	m := NewManager()
+	rec := aoitest.NewRecorder()
+	m.SetCallback(rec)
+	pos := make(map[aoi.EntityID][3]aoi.Float)
+	randPos := func() aoi.Position {
+		return aoi.Position{X: aoi.Float(rnd.Intn(200) - 100), Y: aoi.Float(rnd.Intn(200) - 100), Z: aoi.Float(rnd.Intn(200) - 100)}
+	}
+	for i := aoi.EntityID(1); i <= n; i++ {
+		p := randPos()
+		pos[i] = [3]aoi.Float{p.X, p.Y, p.Z}
+		m.AddEntity(i, &p, aoi.Float(rnd.Intn(20)))
+	}
+	// 打乱链表，让查询不依赖插入时的索引
+	for step := 0; step < 500; step++ {
+		id := aoi.EntityID(rnd.Intn(n) + 1)
+		p := randPos()
+		pos[id] = [3]aoi.Float{p.X, p.Y, p.Z}
+		m.MoveEntity(id, &p)
+	}
+
+	for q := 0; q < 100; q++ {
+		cp := randPos()
+		c := [3]aoi.Float{cp.X, cp.Y, cp.Z}
+		r := aoi.Float(rnd.Intn(60))
+		var want []aoi.EntityID
+		for id := aoi.EntityID(1); id <= n; id++ {
+			if distSq(c, pos[id]) <= r*r {
+				want = append(want, id)
+			}
+		}
+		if got := m.QueryRadius(cp, r); !slices.Equal(got, want) {
+			t.Fatalf("QueryRadius(%v, %v) = %v, want %v", cp, r, got, want)
+		}
+
+		box := aoi.Box{Min: cp, Max: randPos()}
+		lo, hi := boxOffsets(box)
+		want = want[:0]
+		for id := aoi.EntityID(1); id <= n; id++ {
+			p := pos[id]
+			if p[0] >= lo[0] && p[0] <= hi[0] && p[1] >= lo[1] && p[1] <= hi[1] && p[2] >= lo[2] && p[2] <= hi[2] {
+				want = append(want, id)
+			}
+		}
+		if got := m.QueryBox(box); !slices.Equal(got, want) {
+			t.Fatalf("QueryBox(%v) = %v, want %v", box, got, want)
+		}
+
+		k := rnd.Intn(10) + 1
+		got := m.QueryNearest(cp, k)
+		if len(got) != k {
+			t.Fatalf("QueryNearest returned %d entities, want %d", len(got), k)
+		}
+		// 结果按距离排序，且没有被漏掉的更近的实体
+		for i := 1; i < k; i++ {
+			if distSq(c, pos[got[i-1]]) > distSq(c, pos[got[i]]) {
+				t.Fatalf("QueryNearest result is not sorted: %v", got)
+			}
+		}
+		closer := 0
+		for id := aoi.EntityID(1); id <= n; id++ {
+			if distSq(c, pos[id]) < distSq(c, pos[got[k-1]]) {
+				closer++
+			}
+		}
+		if closer >= k {
+			t.Fatalf("QueryNearest(%v, %d) = %v missed closer entities", cp, k, got)
+		}
+	}
+	if got := m.QueryNearest(aoi.Position{}, 2*n); len(got) != n {
+		t.Fatalf("QueryNearest with k > n returned %d entities", len(got))
+	}
+	for _, c := range []aoi.Position{{X: aoi.Float(math.NaN())}, {Y: aoi.FloatInf(1)}, {Z: aoi.FloatInf(-1)}} {
+		if got := m.QueryNearest(c, 3); got != nil {
+			t.Fatalf("QueryNearest(%v) = %v, want nil", c, got)
+		}
+	}
+	// 位置为 NaN 的实体永远凑不进扫描半径，倍增有上限；极远处的实体仍然能找到
+	m.AddEntity(n+1, &aoi.Position{Y: aoi.Float(math.NaN())}, 0)
+	m.AddEntity(n+2, &aoi.Position{Z: 1e30}, 0)
+	if got := m.QueryNearest(aoi.Position{}, 2*n); len(got) != n+1 || got[n] != n+2 {
+		t.Fatalf("QueryNearest should skip the NaN entity and keep the far one, got %d entities", len(got))
+	}
+	if len(m.players) != 0 || len(rec.Take()) != 0 {
+		t.Fatal("queries must not create players or fire callbacks")
+	}
+}
@@ -0,0 +1,57 @@
+package three_dim
+
+import (
+	"github.com/beijian128/aoi"
+)
+
+var _ aoi.Raycaster = (*Manager)(nil)
+
+// SetBodyRadius 设置包围半径 (球)，只用于射线检测
+func (m *Manager) SetBodyRadius(id aoi.EntityID, r aoi.Float) {
+	e, ok := m.entities[id]
+	if !ok {
+		return
+	}
+	old := e.Body
+	e.Body = max(r, 0)
+	m.maxBody.Add(e.Body)
+	if m.maxBody.Remove(old) {
+		m.resetMaxBody()
+	}
+}
+
+// Raycast 射线检测
+// 被命中的实体中心一定在线段的外接盒向外扩大 maxBody 的范围内，先按这个盒子在最窄的轴上沿链表粗筛，再逐个做射线与球求交
+func (m *Manager) Raycast(origin, dir aoi.Position, maxDist aoi.Float) []aoi.RaycastHit {
+	ray, ok := aoi.NewRay(origin, dir)
+	if !ok || !(maxDist >= 0) {
+		return nil
+	}
+	o := [3]aoi.Float{ray.Origin.X, ray.Origin.Y, ray.Origin.Z}
+	d := [3]aoi.Float{ray.Dir.X, ray.Dir.Y, ray.Dir.Z}
+	var lo, hi [3]aoi.Float
+	for axis := 0; axis < 3; axis++ {
+		end := o[axis]
+		if d[axis] != 0 { // 避免 0*Inf
+			end += d[axis] * maxDist
+		}
+		lo[axis] = min(o[axis], end) - m.maxBody.Max()
+		hi[axis] = max(o[axis], end) + m.maxBody.Max()
+	}
+	var hits []aoi.RaycastHit
+	m.forEachInBox(lo, hi, func(e *Entity) {
+		if t, ok := ray.Hit(aoi.Position{X: e.Pos[0], Y: e.Pos[1], Z: e.Pos[2]}, e.Body); ok && t <= maxDist {
+			hits = append(hits, aoi.RaycastHit{ID: e.ID, Dist: t})
+		}
+	})
+	aoi.SortHits(hits)
+	return hits
+}
+
+// resetMaxBody 重新统计最大包围半径
+func (m *Manager) resetMaxBody() {
+	m.maxBody.Reset()
+	for _, e := range m.entities {
+		m.maxBody.Add(e.Body)
+	}
+}
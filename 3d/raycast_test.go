@@ -0,0 +1,86 @@
+package three_dim
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestRaycast(t *testing.T) {
+	m := NewManager()
+	// 沿 X 轴的射线依次穿过半径为 2 的 3、半径为 0 的 1，2 在射线起点后面，4 偏离射线太远
+	m.AddEntity(1, &aoi.Position{X: 30}, 10)
+	m.AddEntity(2, &aoi.Position{X: -5}, 10)
+	m.AddEntity(3, &aoi.Position{X: 10, Y: 1}, 10)
+	m.AddEntity(4, &aoi.Position{X: 20, Z: 3}, 10)
+	m.SetBodyRadius(3, 2)
+	m.SetBodyRadius(4, 2)
+	got := m.Raycast(aoi.Position{}, aoi.Position{X: 2}, 50)
+	want := []aoi.RaycastHit{{ID: 3, Dist: 10 - aoi.Float(math.Sqrt(3))}, {ID: 1, Dist: 30}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Raycast = %v, want %v", got, want)
+	}
+	// 起点在包围球内
+	if got := m.Raycast(aoi.Position{X: 10}, aoi.Position{Y: -1}, 1); len(got) != 1 || got[0] != (aoi.RaycastHit{ID: 3}) {
+		t.Fatalf("Raycast from inside a body = %v", got)
+	}
+	m.RemoveEntity(3)
+	if m.maxBody.Max() != 2 {
+		t.Fatalf("maxBody = %v after removing one of two widest bodies, want 2", m.maxBody.Max())
+	}
+	m.RemoveEntity(4)
+	if m.maxBody.Max() != 0 {
+		t.Fatalf("maxBody = %v after removing all bodies, want 0", m.maxBody.Max())
+	}
+	m.RemoveEntity(1)
+	m.RemoveEntity(2)
+
+	// 与暴力解比较，包括无限长的射线
+	const n = 300
+	rnd := rand.New(rand.NewSource(1))
+	randPos := func() aoi.Position {
+		return aoi.Position{X: aoi.Float(rnd.Intn(200) - 100), Y: aoi.Float(rnd.Intn(200) - 100), Z: aoi.Float(rnd.Intn(200) - 100)}
+	}
+	pos := make(map[aoi.EntityID]aoi.Position)
+	body := make(map[aoi.EntityID]aoi.Float)
+	for i := aoi.EntityID(1); i <= n; i++ {
+		p := randPos()
+		pos[i] = p
+		m.AddEntity(i, &p, aoi.Float(rnd.Intn(20)))
+		if rnd.Intn(2) == 0 {
+			body[i] = aoi.Float(rnd.Intn(15))
+			m.SetBodyRadius(i, body[i])
+		}
+	}
+	for step := 0; step < 300; step++ {
+		id := aoi.EntityID(rnd.Intn(n) + 1)
+		p := randPos()
+		pos[id] = p
+		m.MoveEntity(id, &p)
+	}
+	for q := 0; q < 200; q++ {
+		origin := randPos()
+		dir := aoi.Position{X: aoi.Float(rnd.Intn(21) - 10), Y: aoi.Float(rnd.Intn(21) - 10), Z: aoi.Float(rnd.Intn(21) - 10)}
+		maxDist := aoi.Float(rnd.Intn(150))
+		if q%10 == 0 {
+			maxDist = aoi.FloatInf(1)
+		}
+		ray, ok := aoi.NewRay(origin, dir)
+		if !ok {
+			continue
+		}
+		var want []aoi.RaycastHit
+		for id, p := range pos {
+			if d, ok := ray.Hit(p, body[id]); ok && d <= maxDist {
+				want = append(want, aoi.RaycastHit{ID: id, Dist: d})
+			}
+		}
+		aoi.SortHits(want)
+		if got := m.Raycast(origin, dir, maxDist); !slices.Equal(got, want) {
+			t.Fatalf("Raycast(%v, %v, %v) = %v, want %v", origin, dir, maxDist, got, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package three_dim
+
+import (
+	"testing"
+
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/aoitest"
+)
+
+func TestRemoveEntity(t *testing.T) {
+	// 坐标远大于旧实现中的 999999
+	const far = 5e6
+	m := NewManager()
+	rec := aoitest.NewRecorder()
+	m.SetCallback(rec)
+	m.AddPlayer(1)
+	m.AddPlayer(2)
+	m.AddEntity(1, &aoi.Position{X: far, Y: far, Z: far}, 10)
+	m.AddEntity(2, &aoi.Position{X: far + 5, Y: far, Z: far}, 10)
+	m.AddEntity(3, &aoi.Position{X: far - 5, Y: far, Z: far}, 10)
+	m.AddEntity(4, &aoi.Position{X: far + 8, Y: 0, Z: far}, 10) // 只在 X/Z 轴上重叠
+	m.Subscribe(1, 1)
+	m.Subscribe(2, 2)
+	rec.Take()
+
+	m.RemoveEntity(1)
+	// 只有真正的 Leave，没有中间过程产生的 Enter/Leave
+	got := rec.Take()
+	want := map[aoitest.Event]bool{
+		{Player: 1, Target: 2}: true,
+		{Player: 1, Target: 3}: true,
+		{Player: 2, Target: 1}: true,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("events = %v", got)
+	}
+	for _, ev := range got {
+		if !want[ev] {
+			t.Fatalf("unexpected event %v in %v", ev, got)
+		}
+	}
+
+	// 其余实体中不再残留对 1 的任何记录
+	for _, e := range m.entities {
+		if _, ok := e.ViewCounts[1]; ok {
+			t.Fatalf("entity %d still counts the removed entity", e.ID)
+		}
+		if _, ok := e.CountedBy[1]; ok {
+			t.Fatalf("entity %d still counted by the removed entity", e.ID)
+		}
+		if e.InBox[1] != nil || e.Watchers[1] != nil || e.VisibleSet[1] {
+			t.Fatalf("entity %d still references the removed entity", e.ID)
+		}
+	}
+	// 其余实体之间的关系不受影响
+	if !m.CanSee(2, 3) {
+		t.Fatal("removal must not disturb other pairs")
+	}
+	m.MoveEntity(3, &aoi.Position{X: far + 100, Y: far, Z: far})
+	if m.CanSee(2, 3) {
+		t.Fatal("lists must stay consistent after removal")
+	}
+}
+
+// 视野相同的实体依次移除时不会每次都重新统计 maxWidth；最宽的实体移除之后，maxWidth 在若干次操作之后收缩
+func TestRemoveMaxWidth(t *testing.T) {
+	const n = 100
+	m := NewManager()
+	m.AddEntity(0, &aoi.Position{}, 1000)
+	for i := aoi.EntityID(1); i <= n; i++ {
+		m.AddEntity(i, &aoi.Position{X: aoi.Float(i)}, 10)
+	}
+	m.RemoveEntity(0)
+	if !m.widthStale || m.maxWidth[0] < 2000 {
+		t.Fatalf("maxWidth should stay as a stale upper bound right after the removal, got %v", m.maxWidth)
+	}
+	for i := aoi.EntityID(1); i <= n; i++ {
+		m.MoveEntity(i, &aoi.Position{X: aoi.Float(i) + 0.5})
+	}
+	if m.widthStale || m.maxWidth[0] > 21 {
+		t.Fatalf("maxWidth should shrink after enough operations, got %v", m.maxWidth)
+	}
+	for i := aoi.EntityID(1); i <= n/2; i++ {
+		m.RemoveEntity(i)
+		// 仍然是真实最大宽度的上界
+		if m.maxWidth[0] < 20 {
+			t.Fatalf("maxWidth %v is below the real widest interval", m.maxWidth)
+		}
+	}
+	if m.widthOps >= len(m.entities) {
+		t.Fatalf("widthOps %d should have triggered a recount", m.widthOps)
+	}
+}
+
+// 玩家只记录自己订阅的实体，实体移除、取消订阅之后不再残留
+func TestRemovePlayerSubscriptions(t *testing.T) {
+	m := NewManager()
+	rec := aoitest.NewRecorder()
+	m.SetCallback(rec)
+	m.AddPlayer(1)
+	for i := aoi.EntityID(1); i <= 3; i++ {
+		m.AddEntity(i, &aoi.Position{X: aoi.Float(i)}, 10)
+		m.Subscribe(1, i)
+	}
+	m.Unsubscribe(1, 2)
+	m.RemoveEntity(3)
+	p := m.players[1]
+	if p.Subscriptions.Size() != 1 || !p.Subscriptions.Contains(1) {
+		t.Fatalf("subscriptions = %v", p.Subscriptions)
+	}
+	// 同一个 ID 重新添加的实体不是原来订阅的那个
+	m.AddEntity(3, &aoi.Position{X: 3}, 10)
+	rec.Take()
+	m.RemovePlayer(1, true)
+	if len(m.entities[1].Subscribers) != 0 {
+		t.Fatal("RemovePlayer must cancel the remaining subscription")
+	}
+	if got := rec.Take(); len(got) != 2 {
+		t.Fatalf("events = %v", got)
+	}
+}
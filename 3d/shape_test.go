@@ -0,0 +1,129 @@
+package three_dim
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/aoitest"
+)
+
+// 球形/圆柱形视野与朝向：在视野盒内移动跨过球面、只转身时也要正确地 Enter/Leave
+func TestNarrowPhaseVisibility(t *testing.T) {
+	const n = 40
+	rnd := rand.New(rand.NewSource(1))
+	m := NewManager()
+	pos := make(map[aoi.EntityID]aoi.Position)
+	shapes := make(map[aoi.EntityID]aoi.Shape)
+	facings := make(map[aoi.EntityID]aoi.Facing)
+	randPos := func() aoi.Position {
+		return aoi.Position{X: aoi.Float(rnd.Intn(60)), Y: aoi.Float(rnd.Intn(60)), Z: aoi.Float(rnd.Intn(60))}
+	}
+	randShape := func() aoi.Shape {
+		return aoi.Shape{
+			Kind:       aoi.ShapeKind(rnd.Intn(3)),
+			Radius:     aoi.Float(rnd.Intn(25)),
+			HalfHeight: aoi.Float(rnd.Intn(10)),
+		}
+	}
+	for i := aoi.EntityID(1); i <= n; i++ {
+		p := randPos()
+		pos[i] = p
+		m.AddPlayer(aoi.PlayerID(i))
+		m.AddEntity(i, &p, 10)
+		m.Subscribe(aoi.PlayerID(i), i)
+		shapes[i] = aoi.Shape{Kind: aoi.ShapeBox}
+	}
+	// boxes 记录 ShapeBox 实体当前的视野盒
+	boxes := make(map[aoi.EntityID]aoi.Box)
+	for i := aoi.EntityID(1); i <= n; i++ {
+		boxes[i] = aoi.CubeBox(10)
+	}
+	for step := 0; step < 3000; step++ {
+		id := aoi.EntityID(rnd.Intn(n) + 1)
+		switch rnd.Intn(5) {
+		case 0:
+			s := randShape()
+			if s.Kind == aoi.ShapeBox {
+				s = shapes[id]
+				s.Kind = aoi.ShapeBox
+			} else {
+				boxes[id] = s.Bounds()
+			}
+			shapes[id] = s
+			m.SetShape(id, s)
+		case 1:
+			r := aoi.Float(rnd.Intn(20))
+			m.SetRange(id, r)
+			if s := shapes[id]; s.Kind != aoi.ShapeBox {
+				s.Radius = r
+				shapes[id] = s
+				boxes[id] = s.Bounds()
+			} else {
+				boxes[id] = aoi.CubeBox(r)
+			}
+		case 2:
+			if rnd.Intn(4) == 0 {
+				facings[id] = aoi.Facing{}
+				m.SetFacing(id, nil, 0)
+				break
+			}
+			f := aoi.Facing{
+				Dir: aoi.Position{X: aoi.Float(rnd.Intn(3) - 1), Y: aoi.Float(rnd.Intn(3) - 1), Z: aoi.Float(rnd.Intn(3) - 1)},
+				FOV: aoi.Float(rnd.Float64() * 2 * math.Pi),
+			}
+			facings[id] = f
+			m.SetFacing(id, &f.Dir, f.FOV)
+		default:
+			// 小步移动，让实体经常在视野盒内部跨过球面
+			p := pos[id]
+			p.X += aoi.Float(rnd.Intn(7) - 3)
+			p.Y += aoi.Float(rnd.Intn(7) - 3)
+			p.Z += aoi.Float(rnd.Intn(7) - 3)
+			pos[id] = p
+			m.MoveEntity(id, &p)
+		}
+		if step%100 != 0 {
+			continue
+		}
+		for w := aoi.EntityID(1); w <= n; w++ {
+			for tgt := aoi.EntityID(1); tgt <= n; tgt++ {
+				d := aoi.Position{X: pos[tgt].X - pos[w].X, Y: pos[tgt].Y - pos[w].Y, Z: pos[tgt].Z - pos[w].Z}
+				want := w != tgt && inBox(pos[w], boxes[w], pos[tgt]) && shapes[w].Contains(d) && facings[w].Contains(d)
+				if got := m.CanSee(aoi.PlayerID(w), tgt); got != want {
+					t.Fatalf("step %d: CanSee(%d, %d) = %v, want %v (shape %+v, d %+v)", step, w, tgt, got, want, shapes[w], d)
+				}
+			}
+		}
+	}
+}
+
+func TestFacing(t *testing.T) {
+	m := NewManager()
+	rec := aoitest.NewRecorder()
+	m.SetCallback(rec)
+	m.AddPlayer(1)
+	m.AddEntity(1, &aoi.Position{X: 50, Y: 50, Z: 50}, 20)
+	m.Subscribe(1, 1)
+	m.AddEntity(2, &aoi.Position{X: 60, Y: 50, Z: 50}, 0) // 正前方 (+X)
+	m.AddEntity(3, &aoi.Position{X: 40, Y: 50, Z: 50}, 0) // 正后方
+	m.AddEntity(4, &aoi.Position{X: 50, Y: 60, Z: 50}, 0) // 正上方
+
+	m.SetFacing(1, &aoi.Position{X: 1}, math.Pi/2)
+	if !m.CanSee(1, 2) || m.CanSee(1, 3) || m.CanSee(1, 4) {
+		t.Fatalf("guard should only see what is in front of it, view %v", m.GetView(1))
+	}
+	rec.Take()
+
+	// 抬头看正上方
+	m.SetFacing(1, &aoi.Position{Y: 1}, math.Pi/2)
+	if got := rec.Take(); len(got) != 2 || !m.CanSee(1, 4) || m.CanSee(1, 2) {
+		t.Fatalf("rotating should enter/leave without moving, events %v", got)
+	}
+
+	m.SetFacing(1, nil, 0)
+	if m.GetView(1).Size() != 3 {
+		t.Fatalf("clearing the facing should restore the full box, view %v", m.GetView(1))
+	}
+}
@@ -0,0 +1,48 @@
+package three_dim
+
+import (
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestStealth(t *testing.T) {
+	m := NewManager()
+	m.AddPlayer(1)
+	m.AddEntity(1, &aoi.Position{X: 50, Y: 50, Z: 50}, 30) // 守卫
+	m.Subscribe(1, 1)
+	m.SetStealth(1, aoi.Stealth{Detection: 1, RevealRadius: 5})
+	m.AddEntity(2, &aoi.Position{X: 70, Y: 50, Z: 50}, 10) // 盗贼
+	m.SetStealth(2, aoi.Stealth{Level: 2})
+	if m.CanSee(1, 2) {
+		t.Fatal("stealthed rogue should be hidden from a low-detection guard")
+	}
+	// ViewCounts 仍然记录几何关系
+	if m.entities[1].ViewCounts[2] != 3 {
+		t.Fatalf("ViewCounts = %d, want 3", m.entities[1].ViewCounts[2])
+	}
+
+	// 在视野盒内走进揭示半径
+	m.MoveEntity(2, &aoi.Position{X: 53, Y: 52, Z: 50})
+	if !m.CanSee(1, 2) {
+		t.Fatal("rogue inside the reveal radius should be visible")
+	}
+	m.MoveEntity(2, &aoi.Position{X: 60, Y: 50, Z: 50})
+	if m.CanSee(1, 2) {
+		t.Fatal("rogue leaving the reveal radius should be hidden again")
+	}
+
+	m.SetStealth(2, aoi.Stealth{})
+	if !m.CanSee(1, 2) {
+		t.Fatal("rogue leaving stealth should be visible")
+	}
+
+	m.SetVisibilityRule(aoi.VisibilityRuleFunc(func(w, tgt aoi.RuleSubject) bool { return tgt.ID != 2 }))
+	if m.CanSee(1, 2) {
+		t.Fatal("custom rule should hide entity 2")
+	}
+	m.SetVisibilityRule(nil)
+	if !m.CanSee(1, 2) {
+		t.Fatal("resetting the rule should fall back to the stealth rule")
+	}
+}
@@ -0,0 +1,31 @@
+package three_dim
+
+import (
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/internal/strict"
+)
+
+// strictChecks 严格模式对 Manager 的校验
+type strictChecks struct {
+	m *Manager
+}
+
+// Strict 返回严格模式的视图，与 m 共享同一份数据
+func (m *Manager) Strict() aoi.StrictAOIManager {
+	return strict.New(m, strictChecks{m: m})
+}
+
+func (c strictChecks) HasPlayer(id aoi.PlayerID) bool {
+	_, ok := c.m.players[id]
+	return ok
+}
+
+func (c strictChecks) HasEntity(id aoi.EntityID) bool {
+	_, ok := c.m.entities[id]
+	return ok
+}
+
+// ValidPos 坐标必须是有限的数 (十字链表用 ±Inf 做哨兵，NaN 和无穷大会破坏链表的顺序)
+func (c strictChecks) ValidPos(pos *aoi.Position) bool {
+	return pos.X.IsFinite() && pos.Y.IsFinite() && pos.Z.IsFinite()
+}
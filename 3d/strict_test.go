@@ -0,0 +1,40 @@
+package three_dim
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestStrict(t *testing.T) {
+	m := NewManager()
+	s := m.Strict()
+	check := func(step string, err, want error) {
+		t.Helper()
+		if !errors.Is(err, want) {
+			t.Fatalf("%s: err = %v, want %v", step, err, want)
+		}
+	}
+	check("add player", s.AddPlayer(1), nil)
+	check("duplicate player", s.AddPlayer(1), aoi.ErrDuplicatePlayer)
+	check("add entity", s.AddEntity(1, &aoi.Position{X: 50, Y: 50, Z: 50}, 20), nil)
+	check("duplicate entity", s.AddEntity(1, &aoi.Position{}, 20), aoi.ErrDuplicateEntity)
+	check("nil pos", s.AddEntity(2, nil, 20), aoi.ErrNilPosition)
+	check("infinite pos", s.AddEntity(2, &aoi.Position{X: aoi.FloatInf(1)}, 20), aoi.ErrOutOfBounds)
+	check("move unknown", s.MoveEntity(2, &aoi.Position{}), aoi.ErrEntityNotFound)
+	check("move nil", s.MoveEntity(1, nil), aoi.ErrNilPosition)
+	check("subscribe missing entity", s.Subscribe(1, 2), aoi.ErrEntityNotFound)
+	check("subscribe missing player", s.Subscribe(2, 1), aoi.ErrPlayerNotFound)
+	check("subscribe", s.Subscribe(1, 1), nil)
+	check("add target", s.AddEntity(2, &aoi.Position{X: 60, Y: 50, Z: 50}, 0), nil)
+	if ok, err := s.CanSee(1, 2); err != nil || !ok {
+		t.Fatalf("CanSee = %v, %v", ok, err)
+	}
+	check("unsubscribe missing player", s.Unsubscribe(2, 1), aoi.ErrPlayerNotFound)
+	check("remove", s.RemoveEntity(2), nil)
+	check("remove twice", s.RemoveEntity(2), aoi.ErrEntityNotFound)
+	if len(m.entities) != 1 || len(m.GetView(1)) != 0 {
+		t.Fatal("failed calls must not change the manager")
+	}
+}
@@ -2,6 +2,7 @@ package aoi
 
 import (
 	"math"
+	"time"
 )
 
 type (
@@ -14,6 +15,15 @@ func (f *Float) IsInf(sign int) bool {
 	return math.IsInf(float64(*f), sign)
 }
 
+func (f *Float) IsNaN() bool {
+	return math.IsNaN(float64(*f))
+}
+
+// IsFinite 既不是 NaN 也不是无穷大
+func (f *Float) IsFinite() bool {
+	return !math.IsNaN(float64(*f)) && !math.IsInf(float64(*f), 0)
+}
+
 func FloatInf(sign int) Float {
 	return Float(math.Inf(sign))
 }
@@ -23,6 +33,95 @@ type Position struct {
 	X, Y, Z Float
 }
 
+// Box 轴对齐的视野盒
+// Min/Max 是相对实体位置的偏移，每个轴上需满足 Min <= Max，
+// 例如水下单位的"细高"视野: Box{Min: Position{-5, -50, -5}, Max: Position{5, 50, 5}}
+type Box struct {
+	Min, Max Position
+}
+
+// CubeBox 半边长为 r 的立方体视野盒，等价于 AddEntity/SetRange 中的 rangeVal
+func CubeBox(r Float) Box {
+	return Box{Min: Position{X: -r, Y: -r, Z: -r}, Max: Position{X: r, Y: r, Z: r}}
+}
+
+// ShapeKind 视野形状
+type ShapeKind int
+
+const (
+	ShapeBox      ShapeKind = iota // 视野盒本身 (默认)
+	ShapeSphere                    // 球: 与实体的距离 <= Radius
+	ShapeCylinder                  // 竖直圆柱: XZ 平面上的距离 <= Radius，且 Y 方向相差 <= HalfHeight
+)
+
+// Shape 精确的视野形状
+// 管理器先用形状的外接盒做粗筛，三个轴都重叠之后再按形状做精确判定
+type Shape struct {
+	Kind       ShapeKind
+	Radius     Float
+	HalfHeight Float // 只对 ShapeCylinder 有效
+}
+
+// Bounds 形状的外接视野盒
+func (s Shape) Bounds() Box {
+	switch s.Kind {
+	case ShapeSphere:
+		return CubeBox(s.Radius)
+	case ShapeCylinder:
+		return Box{
+			Min: Position{X: -s.Radius, Y: -s.HalfHeight, Z: -s.Radius},
+			Max: Position{X: s.Radius, Y: s.HalfHeight, Z: s.Radius},
+		}
+	}
+	return Box{}
+}
+
+// Contains 相对观察者的偏移 d 是否落在形状内 (ShapeBox 总是返回 true，由视野盒自己判定)
+func (s Shape) Contains(d Position) bool {
+	switch s.Kind {
+	case ShapeSphere:
+		return d.X*d.X+d.Y*d.Y+d.Z*d.Z <= s.Radius*s.Radius
+	case ShapeCylinder:
+		return d.X*d.X+d.Z*d.Z <= s.Radius*s.Radius && d.Y >= -s.HalfHeight && d.Y <= s.HalfHeight
+	}
+	return true
+}
+
+// Facing 朝向与视野张角 (锥形/扇形视野)
+// FOV 是整个张角 (弧度)，<= 0 或 >= 2π 时不做限制；Dir 为零向量时同样不做限制
+type Facing struct {
+	Dir Position
+	FOV Float
+}
+
+// Enabled 是否需要按朝向过滤
+func (f Facing) Enabled() bool {
+	return f.FOV > 0 && f.FOV < 2*math.Pi && (f.Dir.X != 0 || f.Dir.Y != 0 || f.Dir.Z != 0)
+}
+
+// Contains 相对观察者的偏移 d 是否落在视野锥内 (边界算在内，与观察者重合的目标总是在内)
+func (f Facing) Contains(d Position) bool {
+	if !f.Enabled() {
+		return true
+	}
+	dot := float64(d.X*f.Dir.X + d.Y*f.Dir.Y + d.Z*f.Dir.Z)
+	dLen := math.Sqrt(float64(d.X*d.X + d.Y*d.Y + d.Z*d.Z))
+	if dLen == 0 {
+		return true
+	}
+	fLen := math.Sqrt(float64(f.Dir.X*f.Dir.X + f.Dir.Y*f.Dir.Y + f.Dir.Z*f.Dir.Z))
+	// 留一点余量，避免正好在边界上的目标因为浮点误差闪烁
+	return dot >= dLen*fLen*math.Cos(float64(f.FOV)/2)-1e-9
+}
+
+// LayerMask 可见性层 (位掩码)
+// 每个实体有 emit (我属于哪些层) 和 sense (我能感知哪些层) 两个掩码，
+// watcher 能看见 target 当且仅当 watcher.sense & target.emit != 0
+type LayerMask uint64
+
+// LayerAll 所有层，实体默认的 emit/sense 掩码
+const LayerAll LayerMask = ^LayerMask(0)
+
 // Player 玩家 (逻辑层)
 // 它是视野的订阅者，本身没有坐标，汇总其下属 Entity 的视野
 type Player struct {
@@ -30,6 +129,11 @@ type Player struct {
 	// FinalView: 聚合后的视野
 	// Key: TargetID, Value: 引用计数 (有多少个我的单位看见了这个目标)
 	FinalView map[EntityID]int
+	// PendingLeave: 已经离开视野、正在等待延迟 Leave 的目标 (不在 FinalView 中)
+	// Key: TargetID, Value: 到期时间
+	PendingLeave map[EntityID]time.Time
+	// Subscriptions: 订阅的实体，移除玩家时只需要取消这些订阅
+	Subscriptions Set[EntityID]
 }
 
 // AOICallback 回调接口：处理视野进出事件
@@ -42,9 +146,14 @@ type AOICallback interface {
 
 type AOIManager interface {
 	AddPlayer(id PlayerID)
+	// RemovePlayer 移除玩家 (例如客户端断线)，取消它的所有订阅并释放内存
+	// emitLeave 为 true 时对视野内的所有目标触发 OnLeave
+	RemovePlayer(id PlayerID, emitLeave bool)
 	AddEntity(id EntityID, pos *Position, rangeVal Float)
 	RemoveEntity(id EntityID)
 	MoveEntity(id EntityID, pos *Position)
+	// SetRange 运行时修改实体的视野半径 (buff、昼夜、瞄准镜等)
+	SetRange(id EntityID, r Float)
 	// GetView 获取视野内所有目标 ID
 	GetView(id PlayerID) Set[EntityID]
 	// CanSee watcherId 是否能看见 targetId
@@ -56,3 +165,53 @@ type AOIManager interface {
 	// SetCallback 设置上层业务回调
 	SetCallback(cb AOICallback)
 }
+
+// BoxRangeManager 支持按轴设置视野范围 (长方体视野) 的管理器
+type BoxRangeManager interface {
+	AOIManager
+	// AddEntityBox 以视野盒 box 添加实体
+	AddEntityBox(id EntityID, pos *Position, box Box)
+	// SetBox 运行时修改实体的视野盒
+	SetBox(id EntityID, box Box)
+}
+
+// ShapeManager 支持球形、圆柱形等精确视野形状的管理器
+type ShapeManager interface {
+	AOIManager
+	// SetShape 设置实体的视野形状，视野盒会同时改为形状的外接盒
+	// ShapeBox 表示取消精确判定，保留当前的视野盒
+	SetShape(id EntityID, shape Shape)
+}
+
+// FacingManager 支持朝向与视野张角的管理器
+type FacingManager interface {
+	AOIManager
+	// SetFacing 设置实体的朝向 facing 与视野张角 fov (弧度)，facing 为 nil 时取消朝向限制
+	// 只转身不移动同样会触发 Enter/Leave
+	SetFacing(id EntityID, facing *Position, fov Float)
+}
+
+// LayerManager 支持可见性层的管理器 (幽灵只对死亡玩家可见、GM 隐身、任务物品分相位可见等)
+type LayerManager interface {
+	AOIManager
+	// SetLayers 设置实体的 emit/sense 掩码，受影响的订阅者会收到 Enter/Leave
+	SetLayers(id EntityID, emit, sense LayerMask)
+}
+
+// HysteresisManager 支持离开余量 (滞回) 的管理器
+type HysteresisManager interface {
+	AOIManager
+	// SetLeaveMargin 进入视野仍按视野范围判定，已经可见的目标要超出视野范围 margin 才会 Leave
+	SetLeaveMargin(margin Float)
+}
+
+// SpatialQuery 与玩家视野无关的空间查询 (技能选目标、范围伤害、刷怪点检查等)
+// 只读，不会创建玩家，也不会触发回调；2D 管理器只使用 X/Z 坐标
+type SpatialQuery interface {
+	// QueryRadius 与 center 的距离不超过 r 的所有实体，按 ID 排序
+	QueryRadius(center Position, r Float) []EntityID
+	// QueryBox 落在 box 中的所有实体 (box 为世界坐标，边界算在内)，按 ID 排序
+	QueryBox(box Box) []EntityID
+	// QueryNearest 离 center 最近的 k 个实体，按距离从近到远排列 (距离相同时按 ID)；center 不是有限的坐标时没有结果
+	QueryNearest(center Position, k int) []EntityID
+}
@@ -0,0 +1,374 @@
+// Package aoitest 为 aoi.AOIManager 的各种实现提供通用的行为校验
+package aoitest
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+// Factory 创建一个全新的、空的管理器
+type Factory func() aoi.AOIManager
+
+// Origin 用例中所有坐标都以它为基准，X/Z 均在 [0,1000] 内，Y 恒为 0，
+// 这样 2D (XZ 平面上的圆) 和 3D (立方体) 的实现都能使用同一套用例
+var Origin = aoi.Position{X: 500, Y: 0, Z: 500}
+
+// At 返回沿 X 轴偏移 dx 的位置
+func At(dx aoi.Float) *aoi.Position {
+	return &aoi.Position{X: Origin.X + dx, Y: Origin.Y, Z: Origin.Z}
+}
+
+// RunConformance 对 factory 创建的管理器运行全部行为校验
+// 任何 aoi.AOIManager 实现都应该通过这些用例
+func RunConformance(t *testing.T, factory Factory) {
+	t.Run("EnterLeave", func(t *testing.T) { testEnterLeave(t, factory) })
+	t.Run("SubscribeRefCount", func(t *testing.T) { testSubscribeRefCount(t, factory) })
+	t.Run("SubscribeSnapshot", func(t *testing.T) { testSubscribeSnapshot(t, factory) })
+	t.Run("MultiplePlayers", func(t *testing.T) { testMultiplePlayers(t, factory) })
+	t.Run("RemoveEntity", func(t *testing.T) { testRemoveEntity(t, factory) })
+	t.Run("RemovePlayer", func(t *testing.T) { testRemovePlayer(t, factory) })
+	t.Run("SetRange", func(t *testing.T) { testSetRange(t, factory) })
+	t.Run("UnknownIDs", func(t *testing.T) { testUnknownIDs(t, factory) })
+	t.Run("ViewConsistency", func(t *testing.T) { testViewConsistency(t, factory) })
+}
+
+// setup 创建管理器并挂上 Recorder
+func setup(factory Factory) (aoi.AOIManager, *Recorder) {
+	m := factory()
+	rec := NewRecorder()
+	m.SetCallback(rec)
+	return m, rec
+}
+
+// expectEvents 校验自上次调用以来的事件 (不关心顺序)
+// 一次操作中同一对目标先 Enter 再 Leave (或反过来) 是允许的，只比较抵消之后的净变化；
+// 回调是否严格交替由 Recorder 负责检查
+func expectEvents(t *testing.T, rec *Recorder, step string, want ...Event) {
+	t.Helper()
+	if len(rec.Violations) > 0 {
+		t.Fatalf("%s: callback contract violated: %v", step, rec.Violations)
+	}
+	got := rec.Take()
+	type pair struct {
+		p aoi.PlayerID
+		e aoi.EntityID
+	}
+	net := make(map[pair]int)
+	for _, e := range got {
+		if e.Enter {
+			net[pair{e.Player, e.Target}]++
+		} else {
+			net[pair{e.Player, e.Target}]--
+		}
+	}
+	for _, e := range want {
+		if e.Enter {
+			net[pair{e.Player, e.Target}]--
+		} else {
+			net[pair{e.Player, e.Target}]++
+		}
+	}
+	for _, n := range net {
+		if n != 0 {
+			t.Fatalf("%s: got events %v, want %v", step, got, want)
+		}
+	}
+}
+
+func enter(p aoi.PlayerID, e aoi.EntityID) Event {
+	return Event{Player: p, Target: e, Enter: true}
+}
+
+func leave(p aoi.PlayerID, e aoi.EntityID) Event {
+	return Event{Player: p, Target: e, Enter: false}
+}
+
+func testEnterLeave(t *testing.T, factory Factory) {
+	m, rec := setup(factory)
+	m.AddPlayer(1)
+	m.AddEntity(1, At(0), 10)
+	m.Subscribe(1, 1)
+	expectEvents(t, rec, "subscribe own entity")
+	if m.CanSee(1, 1) {
+		t.Fatal("an entity must not see itself")
+	}
+
+	m.AddEntity(2, At(5), 0)
+	expectEvents(t, rec, "add target in range", enter(1, 2))
+	m.AddEntity(3, At(50), 0)
+	expectEvents(t, rec, "add target out of range")
+
+	m.MoveEntity(2, At(6))
+	expectEvents(t, rec, "move inside range")
+	m.MoveEntity(2, At(50))
+	expectEvents(t, rec, "target moves out", leave(1, 2))
+	m.MoveEntity(2, At(-5))
+	expectEvents(t, rec, "target moves back in", enter(1, 2))
+
+	m.MoveEntity(1, At(45))
+	expectEvents(t, rec, "watcher moves", leave(1, 2), enter(1, 3))
+	if !m.CanSee(1, 3) || m.CanSee(1, 2) {
+		t.Fatal("CanSee disagrees with callbacks after watcher moved")
+	}
+}
+
+func testSubscribeRefCount(t *testing.T, factory Factory) {
+	m, rec := setup(factory)
+	m.AddPlayer(1)
+	m.AddEntity(1, At(0), 10)
+	m.AddEntity(2, At(8), 10)
+	m.Subscribe(1, 1)
+	m.Subscribe(1, 2)
+	// 两个单位同时看见 1 号 → 同时看见目标 3，只应收到一次 Enter
+	expectEvents(t, rec, "subscribe two watchers", enter(1, 2), enter(1, 1))
+
+	m.AddEntity(3, At(4), 0)
+	expectEvents(t, rec, "target seen by both watchers", enter(1, 3))
+
+	m.MoveEntity(1, At(-100))
+	expectEvents(t, rec, "one watcher moves away", leave(1, 2), leave(1, 1))
+	if !m.CanSee(1, 3) {
+		t.Fatal("target still seen by the other watcher")
+	}
+
+	m.Subscribe(1, 2)
+	m.Unsubscribe(1, 2)
+	expectEvents(t, rec, "duplicate subscribe + one unsubscribe", leave(1, 3))
+
+	m.Unsubscribe(1, 2)
+	expectEvents(t, rec, "unsubscribe twice")
+
+	m.Subscribe(1, 2)
+	expectEvents(t, rec, "subscribe again", enter(1, 3))
+	if got := m.GetView(1); got.Size() != 1 || !got.Contains(3) {
+		t.Fatalf("GetView = %v, want {3}", got)
+	}
+}
+
+func testSubscribeSnapshot(t *testing.T, factory Factory) {
+	m, rec := setup(factory)
+	m.AddEntity(1, At(0), 10)
+	m.AddEntity(2, At(3), 0)
+	m.AddEntity(3, At(-3), 0)
+	m.AddEntity(4, At(30), 0)
+	m.AddPlayer(1)
+	expectEvents(t, rec, "no subscribers yet")
+
+	m.Subscribe(1, 1)
+	expectEvents(t, rec, "subscribe delivers current view", enter(1, 2), enter(1, 3))
+	m.Unsubscribe(1, 1)
+	expectEvents(t, rec, "unsubscribe retracts current view", leave(1, 2), leave(1, 3))
+	if m.GetView(1).Size() != 0 {
+		t.Fatal("view must be empty after unsubscribing")
+	}
+}
+
+func testMultiplePlayers(t *testing.T, factory Factory) {
+	m, rec := setup(factory)
+	m.AddPlayer(1)
+	m.AddPlayer(2)
+	m.AddEntity(1, At(0), 10)
+	m.Subscribe(1, 1)
+	m.Subscribe(2, 1)
+	m.AddEntity(2, At(1), 0)
+	expectEvents(t, rec, "shared watcher", enter(1, 2), enter(2, 2))
+	m.Unsubscribe(2, 1)
+	expectEvents(t, rec, "one player unsubscribes", leave(2, 2))
+	if !m.CanSee(1, 2) || m.CanSee(2, 2) {
+		t.Fatal("unsubscribe must only affect its own player")
+	}
+}
+
+func testRemoveEntity(t *testing.T, factory Factory) {
+	m, rec := setup(factory)
+	m.AddPlayer(1)
+	m.AddEntity(1, At(0), 10)
+	m.Subscribe(1, 1)
+	m.AddEntity(2, At(1), 0)
+	m.AddEntity(3, At(2), 0)
+	rec.Take()
+
+	m.RemoveEntity(2)
+	expectEvents(t, rec, "remove visible target", leave(1, 2))
+	if m.CanSee(1, 2) {
+		t.Fatal("removed target still visible")
+	}
+
+	m.RemoveEntity(1)
+	expectEvents(t, rec, "remove subscribed watcher", leave(1, 3))
+	if m.GetView(1).Size() != 0 {
+		t.Fatal("view must be empty after its only watcher is removed")
+	}
+
+	// 已移除的 ID 上的操作都是空操作
+	m.RemoveEntity(1)
+	m.MoveEntity(1, At(2))
+	m.Subscribe(1, 1)
+	m.Unsubscribe(1, 1)
+	expectEvents(t, rec, "operations on removed entity")
+
+	// 重新添加同一个 ID 之后是一个全新的实体，不继承旧的订阅
+	m.AddEntity(1, At(0), 10)
+	expectEvents(t, rec, "re-add without subscription")
+	m.Subscribe(1, 1)
+	expectEvents(t, rec, "re-subscribe", enter(1, 3))
+}
+
+func testRemovePlayer(t *testing.T, factory Factory) {
+	m, rec := setup(factory)
+	m.AddPlayer(1)
+	m.AddPlayer(2)
+	m.AddEntity(1, At(0), 10)
+	m.AddEntity(2, At(1), 10)
+	m.AddEntity(3, At(2), 0)
+	m.Subscribe(1, 1)
+	m.Subscribe(2, 2)
+	rec.Take()
+
+	m.RemovePlayer(1, true)
+	expectEvents(t, rec, "remove player with leave", leave(1, 2), leave(1, 3))
+	if m.GetView(1).Size() != 0 || m.CanSee(1, 3) {
+		t.Fatal("removed player must have an empty view")
+	}
+	// 已移除玩家的订阅不再生效
+	m.MoveEntity(3, At(-1))
+	m.Unsubscribe(1, 1)
+	m.Subscribe(1, 1)
+	expectEvents(t, rec, "operations on removed player")
+	if !m.CanSee(2, 1) || !m.CanSee(2, 3) {
+		t.Fatal("removing one player must not affect the others")
+	}
+
+	// 断线重连：同一个 ID 重新添加后是全新的玩家
+	m.AddPlayer(1)
+	expectEvents(t, rec, "re-add player")
+	m.Subscribe(1, 1)
+	expectEvents(t, rec, "re-subscribe after reconnect", enter(1, 2), enter(1, 3))
+	m.Unsubscribe(1, 1)
+	expectEvents(t, rec, "unsubscribe after reconnect", leave(1, 2), leave(1, 3))
+
+	// 不触发 Leave 的移除
+	m.RemovePlayer(2, false)
+	expectEvents(t, rec, "silent remove")
+	// 没有 Leave 时业务层自己丢弃了这个玩家的视野，换一个 Recorder 模拟
+	rec = NewRecorder()
+	m.SetCallback(rec)
+	m.AddPlayer(2)
+	m.Subscribe(2, 2)
+	expectEvents(t, rec, "reconnect after silent remove", enter(2, 1), enter(2, 3))
+
+	m.RemovePlayer(42, true)
+	expectEvents(t, rec, "unknown player")
+}
+
+func testSetRange(t *testing.T, factory Factory) {
+	m, rec := setup(factory)
+	m.AddPlayer(1)
+	m.AddPlayer(2)
+	m.AddEntity(1, At(0), 10)
+	m.AddEntity(2, At(15), 20)
+	m.AddEntity(3, At(30), 0)
+	m.Subscribe(1, 1)
+	m.Subscribe(2, 2)
+	rec.Take()
+
+	m.SetRange(1, 20)
+	expectEvents(t, rec, "range grows", enter(1, 2))
+	m.SetRange(1, 40)
+	expectEvents(t, rec, "range grows again", enter(1, 3))
+	m.SetRange(1, 5)
+	expectEvents(t, rec, "range shrinks", leave(1, 2), leave(1, 3))
+
+	// 修改 1 的视野不影响它是否被别人看见
+	if !m.CanSee(2, 1) {
+		t.Fatal("SetRange must not change who can see the entity")
+	}
+	m.SetRange(2, 0)
+	expectEvents(t, rec, "other watcher shrinks", leave(2, 1), leave(2, 3))
+
+	// 修改后的半径在之后的移动中依然生效
+	m.MoveEntity(3, At(4))
+	expectEvents(t, rec, "move into new range", enter(1, 3))
+	m.MoveEntity(3, At(6))
+	expectEvents(t, rec, "move out of new range", leave(1, 3))
+
+	m.SetRange(42, 10)
+	expectEvents(t, rec, "unknown entity")
+}
+
+func testUnknownIDs(t *testing.T, factory Factory) {
+	m, rec := setup(factory)
+	if m.GetView(42).Size() != 0 {
+		t.Fatal("unknown player must have an empty view")
+	}
+	if m.CanSee(42, 42) {
+		t.Fatal("unknown player can not see anything")
+	}
+	m.AddEntity(1, At(0), 10)
+	m.Subscribe(42, 1)
+	m.Unsubscribe(42, 1)
+	m.AddPlayer(1)
+	m.Subscribe(1, 42)
+	m.Unsubscribe(1, 42)
+	m.MoveEntity(42, At(1))
+	m.RemoveEntity(42)
+	expectEvents(t, rec, "operations on unknown ids")
+}
+
+// testViewConsistency 随机操作，每一步之后 GetView/CanSee 都必须与回调还原出的视野一致
+func testViewConsistency(t *testing.T, factory Factory) {
+	m, rec := setup(factory)
+	rnd := rand.New(rand.NewSource(1))
+	const (
+		numPlayers  = 4
+		numEntities = 30
+	)
+	alive := make(map[aoi.EntityID]bool)
+	randPos := func() *aoi.Position {
+		return &aoi.Position{
+			X: Origin.X + aoi.Float(rnd.Intn(200)-100),
+			Y: Origin.Y,
+			Z: Origin.Z + aoi.Float(rnd.Intn(200)-100),
+		}
+	}
+	for p := aoi.PlayerID(1); p <= numPlayers; p++ {
+		m.AddPlayer(p)
+	}
+	for step := 0; step < 2000; step++ {
+		id := aoi.EntityID(rnd.Intn(numEntities) + 1)
+		p := aoi.PlayerID(rnd.Intn(numPlayers) + 1)
+		switch op := rnd.Intn(10); {
+		case op < 5:
+			if alive[id] {
+				m.MoveEntity(id, randPos())
+			} else {
+				m.AddEntity(id, randPos(), aoi.Float(rnd.Intn(60)))
+				alive[id] = true
+			}
+		case op < 6:
+			m.RemoveEntity(id)
+			delete(alive, id)
+		case op < 8:
+			m.Subscribe(p, id)
+		default:
+			m.Unsubscribe(p, id)
+		}
+		if len(rec.Violations) > 0 {
+			t.Fatalf("step %d: callback contract violated: %v", step, rec.Violations)
+		}
+		for p := aoi.PlayerID(1); p <= numPlayers; p++ {
+			want := rec.View(p)
+			got := m.GetView(p)
+			if got.Size() != want.Size() || got.Difference(want).Size() != 0 {
+				t.Fatalf("step %d: GetView(%d) = %v, callbacks say %v", step, p, got, want)
+			}
+			for e := aoi.EntityID(1); e <= numEntities; e++ {
+				if m.CanSee(p, e) != want.Contains(e) {
+					t.Fatalf("step %d: CanSee(%d, %d) disagrees with callbacks", step, p, e)
+				}
+			}
+		}
+	}
+}
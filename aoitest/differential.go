@@ -0,0 +1,187 @@
+package aoitest
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+// OpKind 操作类型
+type OpKind int
+
+const (
+	OpAdd OpKind = iota
+	OpMove
+	OpRemove
+	OpSubscribe
+	OpUnsubscribe
+	OpSetRange
+	OpReconnect // 玩家断线 (RemovePlayer) 后立即重新添加
+	opKindCount
+)
+
+// 差分测试使用的 ID 与坐标范围都很小，以便频繁制造坐标相等、视野为 0 等边界情况
+const (
+	diffPlayers  = 3
+	diffEntities = 8
+	diffCoord    = 12
+	diffRange    = 6
+)
+
+// Op 一次对管理器的操作
+type Op struct {
+	Kind   OpKind
+	Entity aoi.EntityID
+	Player aoi.PlayerID
+	Pos    aoi.Position
+	Range  aoi.Float
+}
+
+// String 以 Go 代码的形式输出，方便直接粘贴成回归用例
+func (op Op) String() string {
+	switch op.Kind {
+	case OpAdd:
+		return fmt.Sprintf("m.AddEntity(%d, &aoi.Position{X: %v, Y: %v, Z: %v}, %v)", op.Entity, op.Pos.X, op.Pos.Y, op.Pos.Z, op.Range)
+	case OpMove:
+		return fmt.Sprintf("m.MoveEntity(%d, &aoi.Position{X: %v, Y: %v, Z: %v})", op.Entity, op.Pos.X, op.Pos.Y, op.Pos.Z)
+	case OpRemove:
+		return fmt.Sprintf("m.RemoveEntity(%d)", op.Entity)
+	case OpSubscribe:
+		return fmt.Sprintf("m.Subscribe(%d, %d)", op.Player, op.Entity)
+	case OpUnsubscribe:
+		return fmt.Sprintf("m.Unsubscribe(%d, %d)", op.Player, op.Entity)
+	case OpSetRange:
+		return fmt.Sprintf("m.SetRange(%d, %v)", op.Entity, op.Range)
+	case OpReconnect:
+		return fmt.Sprintf("m.RemovePlayer(%d, true); m.AddPlayer(%d)", op.Player, op.Player)
+	}
+	return fmt.Sprintf("unknown op %d", op.Kind)
+}
+
+// Apply 在管理器上执行该操作
+func (op Op) Apply(m aoi.AOIManager) {
+	switch op.Kind {
+	case OpAdd:
+		pos := op.Pos
+		m.AddEntity(op.Entity, &pos, op.Range)
+	case OpMove:
+		pos := op.Pos
+		m.MoveEntity(op.Entity, &pos)
+	case OpRemove:
+		m.RemoveEntity(op.Entity)
+	case OpSubscribe:
+		m.Subscribe(op.Player, op.Entity)
+	case OpUnsubscribe:
+		m.Unsubscribe(op.Player, op.Entity)
+	case OpSetRange:
+		m.SetRange(op.Entity, op.Range)
+	case OpReconnect:
+		m.RemovePlayer(op.Player, true)
+		m.AddPlayer(op.Player)
+	}
+}
+
+// RandomOps 生成 n 个随机操作
+func RandomOps(rnd *rand.Rand, n int) []Op {
+	data := make([]byte, n*6)
+	rnd.Read(data)
+	return OpsFromBytes(data)
+}
+
+// OpsFromBytes 把任意字节串解码为操作序列 (每 6 个字节一个操作)，供 go test -fuzz 使用
+// 坐标只在 X 和 Z 上变化 (Y 取决于 Z 的奇偶)，2D、3D 实现都能使用
+func OpsFromBytes(data []byte) []Op {
+	ops := make([]Op, 0, len(data)/6)
+	for ; len(data) >= 6; data = data[6:] {
+		op := Op{
+			Kind:   OpKind(data[0]) % opKindCount,
+			Entity: aoi.EntityID(data[1]%diffEntities) + 1,
+			Player: aoi.PlayerID(data[1]/diffEntities%diffPlayers) + 1,
+			Pos: aoi.Position{
+				X: Origin.X + aoi.Float(data[2]%diffCoord),
+				Y: Origin.Y + aoi.Float(data[4]%2),
+				Z: Origin.Z + aoi.Float(data[3]%diffCoord),
+			},
+			Range: aoi.Float(data[5] % diffRange),
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// Diff 在 m 和参考实现 oracle 上依次执行 ops，返回第一次出现分歧时的描述
+// 每一步之后比较所有玩家的 GetView/CanSee，以及两边回调还原出的视野；完全一致时返回 nil
+func Diff(m, oracle aoi.AOIManager, ops []Op) error {
+	rec, oracleRec := NewRecorder(), NewRecorder()
+	m.SetCallback(rec)
+	oracle.SetCallback(oracleRec)
+	for p := aoi.PlayerID(1); p <= diffPlayers; p++ {
+		m.AddPlayer(p)
+		oracle.AddPlayer(p)
+	}
+	for step, op := range ops {
+		op.Apply(m)
+		op.Apply(oracle)
+		if msg := compare(m, oracle, rec, oracleRec); msg != "" {
+			return fmt.Errorf("diverged after step %d: %s\nsequence:\n%s", step, msg, formatOps(ops[:step+1]))
+		}
+	}
+	return nil
+}
+
+func compare(m, oracle aoi.AOIManager, rec, oracleRec *Recorder) string {
+	if len(rec.Violations) > 0 {
+		return fmt.Sprintf("callback contract violated: %v", rec.Violations)
+	}
+	for p := aoi.PlayerID(1); p <= diffPlayers; p++ {
+		want := oracle.GetView(p)
+		got := m.GetView(p)
+		if !sameSet(got, want) {
+			return fmt.Sprintf("GetView(%d) = %v, oracle %v", p, keys(got), keys(want))
+		}
+		if cb := rec.View(p); !sameSet(cb, want) {
+			return fmt.Sprintf("callbacks of player %d say %v, oracle %v", p, keys(cb), keys(want))
+		}
+		for e := aoi.EntityID(1); e <= diffEntities; e++ {
+			if m.CanSee(p, e) != want.Contains(e) {
+				return fmt.Sprintf("CanSee(%d, %d) = %v, oracle %v", p, e, m.CanSee(p, e), want.Contains(e))
+			}
+		}
+	}
+	return ""
+}
+
+func sameSet(a, b aoi.Set[aoi.EntityID]) bool {
+	return a.Size() == b.Size() && a.Difference(b).Empty()
+}
+
+func keys(s aoi.Set[aoi.EntityID]) []aoi.EntityID {
+	res := make([]aoi.EntityID, 0, len(s))
+	for k := range s {
+		res = append(res, k)
+	}
+	return res
+}
+
+func formatOps(ops []Op) string {
+	var sb strings.Builder
+	for _, op := range ops {
+		sb.WriteString("\t")
+		sb.WriteString(op.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// RunDifferential 用多组随机种子对 factory 和 oracle 做差分测试
+func RunDifferential(t *testing.T, factory, oracle Factory, seeds, steps int) {
+	for seed := 0; seed < seeds; seed++ {
+		ops := RandomOps(rand.New(rand.NewSource(int64(seed))), steps)
+		if err := Diff(factory(), oracle(), ops); err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package aoitest
+
+import (
+	"github.com/beijian128/aoi"
+)
+
+// InRangeFunc 判定 target 是否处于 watcher 的视野内
+type InRangeFunc func(watcher, target *aoi.Position, rangeVal aoi.Float) bool
+
+// InCube 三轴立方体判定，边界算在内 (与十字链表、八叉树一致)
+func InCube(watcher, target *aoi.Position, rangeVal aoi.Float) bool {
+	return watcher.X-rangeVal <= target.X && target.X <= watcher.X+rangeVal &&
+		watcher.Y-rangeVal <= target.Y && target.Y <= watcher.Y+rangeVal &&
+		watcher.Z-rangeVal <= target.Z && target.Z <= watcher.Z+rangeVal
+}
+
+// InCircleXZ XZ 平面上的圆形判定，边界算在内 (与 2D 网格、四叉树一致)
+func InCircleXZ(watcher, target *aoi.Position, rangeVal aoi.Float) bool {
+	dx := target.X - watcher.X
+	dz := target.Z - watcher.Z
+	return dx*dx+dz*dz <= rangeVal*rangeVal
+}
+
+type oracleEntity struct {
+	pos         aoi.Position
+	rangeVal    aoi.Float
+	subscribers aoi.Set[aoi.PlayerID]
+}
+
+// Oracle 朴素的 O(n²) 参考实现
+// 每次操作之后都根据坐标和视野半径从头计算所有玩家的视野，再与上一次的结果做差得到回调，
+// 不维护任何增量状态，用来校验其它实现
+type Oracle struct {
+	inRange  InRangeFunc
+	entities map[aoi.EntityID]*oracleEntity
+	players  map[aoi.PlayerID]aoi.Set[aoi.EntityID]
+	cbk      aoi.AOICallback
+}
+
+func NewOracle(inRange InRangeFunc) *Oracle {
+	return &Oracle{
+		inRange:  inRange,
+		entities: make(map[aoi.EntityID]*oracleEntity),
+		players:  make(map[aoi.PlayerID]aoi.Set[aoi.EntityID]),
+	}
+}
+
+func (o *Oracle) SetCallback(cb aoi.AOICallback) {
+	o.cbk = cb
+}
+
+func (o *Oracle) AddPlayer(id aoi.PlayerID) {
+	if _, ok := o.players[id]; !ok {
+		o.players[id] = aoi.NewSet[aoi.EntityID]()
+	}
+}
+
+// RemovePlayer 移除玩家，emitLeave 为 true 时对视野内的所有目标触发 OnLeave
+func (o *Oracle) RemovePlayer(id aoi.PlayerID, emitLeave bool) {
+	view, ok := o.players[id]
+	if !ok {
+		return
+	}
+	for _, e := range o.entities {
+		e.subscribers.Remove(id)
+	}
+	delete(o.players, id)
+	if emitLeave && o.cbk != nil {
+		for eid := range view {
+			o.cbk.OnLeave(id, eid)
+		}
+	}
+}
+
+func (o *Oracle) AddEntity(id aoi.EntityID, pos *aoi.Position, rangeVal aoi.Float) {
+	if pos == nil {
+		return
+	}
+	if _, ok := o.entities[id]; ok {
+		return
+	}
+	o.entities[id] = &oracleEntity{pos: *pos, rangeVal: rangeVal, subscribers: aoi.NewSet[aoi.PlayerID]()}
+	o.recompute()
+}
+
+func (o *Oracle) RemoveEntity(id aoi.EntityID) {
+	if _, ok := o.entities[id]; !ok {
+		return
+	}
+	delete(o.entities, id)
+	o.recompute()
+}
+
+func (o *Oracle) MoveEntity(id aoi.EntityID, pos *aoi.Position) {
+	e, ok := o.entities[id]
+	if !ok || pos == nil {
+		return
+	}
+	e.pos = *pos
+	o.recompute()
+}
+
+func (o *Oracle) SetRange(id aoi.EntityID, r aoi.Float) {
+	e, ok := o.entities[id]
+	if !ok {
+		return
+	}
+	e.rangeVal = r
+	o.recompute()
+}
+
+func (o *Oracle) GetView(id aoi.PlayerID) aoi.Set[aoi.EntityID] {
+	return aoi.NewSet[aoi.EntityID]().Union(o.players[id])
+}
+
+func (o *Oracle) CanSee(watcherId aoi.PlayerID, targetId aoi.EntityID) bool {
+	return o.players[watcherId].Contains(targetId)
+}
+
+func (o *Oracle) Subscribe(subscriber aoi.PlayerID, target aoi.EntityID) {
+	e, ok := o.entities[target]
+	if !ok {
+		return
+	}
+	if _, ok := o.players[subscriber]; !ok {
+		return
+	}
+	e.subscribers.Add(subscriber)
+	o.recompute()
+}
+
+func (o *Oracle) Unsubscribe(subscriber aoi.PlayerID, target aoi.EntityID) {
+	e, ok := o.entities[target]
+	if !ok {
+		return
+	}
+	e.subscribers.Remove(subscriber)
+	o.recompute()
+}
+
+// recompute 从头计算所有玩家的视野，并对变化的部分触发回调
+func (o *Oracle) recompute() {
+	for pid, old := range o.players {
+		view := aoi.NewSet[aoi.EntityID]()
+		for _, w := range o.entities {
+			if !w.subscribers.Contains(pid) {
+				continue
+			}
+			for tid, t := range o.entities {
+				if w != t && o.inRange(&w.pos, &t.pos, w.rangeVal) {
+					view.Add(tid)
+				}
+			}
+		}
+		o.players[pid] = view
+		if o.cbk == nil {
+			continue
+		}
+		old.Difference(view).ForEach(func(tid aoi.EntityID) bool {
+			o.cbk.OnLeave(pid, tid)
+			return false
+		})
+		view.Difference(old).ForEach(func(tid aoi.EntityID) bool {
+			o.cbk.OnEnter(pid, tid)
+			return false
+		})
+	}
+}
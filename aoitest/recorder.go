@@ -0,0 +1,75 @@
+package aoitest
+
+import (
+	"fmt"
+
+	"github.com/beijian128/aoi"
+)
+
+// Event 一次视野回调
+type Event struct {
+	Player aoi.PlayerID
+	Target aoi.EntityID
+	Enter  bool
+}
+
+func (e Event) String() string {
+	if e.Enter {
+		return fmt.Sprintf("Enter(%d, %d)", e.Player, e.Target)
+	}
+	return fmt.Sprintf("Leave(%d, %d)", e.Player, e.Target)
+}
+
+// Recorder 记录所有回调，并根据回调还原出每个玩家的视野
+// 同一对 (Player, Target) 的 Enter/Leave 必须严格交替，否则记为违规
+type Recorder struct {
+	Events     []Event
+	Violations []string
+
+	views map[aoi.PlayerID]aoi.Set[aoi.EntityID]
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		views: make(map[aoi.PlayerID]aoi.Set[aoi.EntityID]),
+	}
+}
+
+func (r *Recorder) OnEnter(watcherID aoi.PlayerID, targetID aoi.EntityID) {
+	r.Events = append(r.Events, Event{Player: watcherID, Target: targetID, Enter: true})
+	view := r.views[watcherID]
+	if view == nil {
+		view = aoi.NewSet[aoi.EntityID]()
+		r.views[watcherID] = view
+	}
+	if view.Contains(targetID) {
+		r.Violations = append(r.Violations, fmt.Sprintf("duplicate Enter(%d, %d)", watcherID, targetID))
+	}
+	view.Add(targetID)
+}
+
+func (r *Recorder) OnLeave(watcherID aoi.PlayerID, targetID aoi.EntityID) {
+	r.Events = append(r.Events, Event{Player: watcherID, Target: targetID, Enter: false})
+	view := r.views[watcherID]
+	if !view.Contains(targetID) {
+		r.Violations = append(r.Violations, fmt.Sprintf("Leave(%d, %d) without Enter", watcherID, targetID))
+		return
+	}
+	view.Remove(targetID)
+}
+
+// View 根据回调还原出的玩家视野
+func (r *Recorder) View(id aoi.PlayerID) aoi.Set[aoi.EntityID] {
+	view := r.views[id]
+	if view == nil {
+		return aoi.NewSet[aoi.EntityID]()
+	}
+	return view
+}
+
+// Take 取出并清空已记录的事件
+func (r *Recorder) Take() []Event {
+	events := r.Events
+	r.Events = nil
+	return events
+}
@@ -0,0 +1,47 @@
+package aoi
+
+import (
+	"time"
+)
+
+// Clock 时钟抽象，延迟 Leave 等与时间有关的特性通过它取当前时间
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock 系统时钟
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ManualClock 手动推进的时钟
+// 按 tick 驱动的服务器每个 tick 调用一次 Advance(tickInterval)，宽限期就可以按 tick 数来配置
+type ManualClock struct {
+	now time.Time
+}
+
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+func (c *ManualClock) Now() time.Time {
+	return c.now
+}
+
+// Advance 时间前进 d
+func (c *ManualClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// GraceManager 支持延迟 Leave 的管理器
+// 目标离开视野后 OnLeave 会推迟 grace，期间目标回到视野则取消这次 Leave (也不会再次 Enter)；
+// 宽限期内 GetView/CanSee 仍然认为目标可见
+type GraceManager interface {
+	AOIManager
+	// SetLeaveGrace 设置时钟与宽限期，clock 为 nil 时使用 SystemClock，grace <= 0 时关闭并立即投递所有推迟的 Leave
+	SetLeaveGrace(clock Clock, grace time.Duration)
+	// Tick 投递所有已经到期的 Leave，需要业务层定期调用
+	Tick()
+}
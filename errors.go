@@ -0,0 +1,32 @@
+package aoi
+
+import (
+	"errors"
+)
+
+// 严格模式 (StrictAOIManager) 返回的错误，使用 errors.Is 判断
+var (
+	ErrEntityNotFound  = errors.New("aoi: entity not found")
+	ErrDuplicateEntity = errors.New("aoi: duplicate entity")
+	ErrPlayerNotFound  = errors.New("aoi: player not found")
+	ErrDuplicatePlayer = errors.New("aoi: duplicate player")
+	ErrOutOfBounds     = errors.New("aoi: position out of bounds")
+	ErrNilPosition     = errors.New("aoi: nil position")
+)
+
+// StrictAOIManager 严格模式的 AOIManager
+// 普通接口遇到未知 ID、重复添加等情况时静默忽略，严格模式则返回错误，方便尽早发现业务逻辑的 bug。
+// 返回错误时管理器的状态不会有任何变化
+type StrictAOIManager interface {
+	AddPlayer(id PlayerID) error
+	RemovePlayer(id PlayerID, emitLeave bool) error
+	AddEntity(id EntityID, pos *Position, rangeVal Float) error
+	RemoveEntity(id EntityID) error
+	MoveEntity(id EntityID, pos *Position) error
+	SetRange(id EntityID, r Float) error
+	GetView(id PlayerID) (Set[EntityID], error)
+	CanSee(watcherId PlayerID, targetId EntityID) (bool, error)
+	Subscribe(subscriber PlayerID, target EntityID) error
+	Unsubscribe(subscriber PlayerID, target EntityID) error
+	SetCallback(cb AOICallback)
+}
@@ -0,0 +1,72 @@
+package aoi
+
+import (
+	"cmp"
+	"slices"
+)
+
+// ViewDelta 一个玩家在一个 tick 内的视野变化，可以直接打成一个网络包
+type ViewDelta struct {
+	Player PlayerID
+	Enter  []EntityID
+	Leave  []EntityID
+}
+
+// EventBuffer 缓冲模式的回调
+// 把它设置为管理器的回调后，事件会先累积起来，直到调用 Flush 才按玩家分组输出。
+// 同一个 tick 内相互抵消的 Enter/Leave (先进后出，或先出后进) 会被丢弃。
+// 与管理器一样不是并发安全的。
+type EventBuffer struct {
+	// net Key: PlayerID -> TargetID, Value: 净变化 (+1 进入, -1 离开, 0 已抵消)
+	net map[PlayerID]map[EntityID]int
+}
+
+func NewEventBuffer() *EventBuffer {
+	return &EventBuffer{
+		net: make(map[PlayerID]map[EntityID]int),
+	}
+}
+
+func (b *EventBuffer) OnEnter(watcherID PlayerID, targetID EntityID) {
+	b.add(watcherID, targetID, 1)
+}
+
+func (b *EventBuffer) OnLeave(watcherID PlayerID, targetID EntityID) {
+	b.add(watcherID, targetID, -1)
+}
+
+func (b *EventBuffer) add(watcherID PlayerID, targetID EntityID, delta int) {
+	targets := b.net[watcherID]
+	if targets == nil {
+		targets = make(map[EntityID]int)
+		b.net[watcherID] = targets
+	}
+	targets[targetID] += delta
+}
+
+// Flush 输出并清空本 tick 累积的事件
+// 结果按 PlayerID 升序排列，每个玩家的 Enter/Leave 也按 EntityID 升序排列；没有净变化的玩家不会出现
+func (b *EventBuffer) Flush() []ViewDelta {
+	res := make([]ViewDelta, 0, len(b.net))
+	for pid, targets := range b.net {
+		delta := ViewDelta{Player: pid}
+		for tid, n := range targets {
+			if n > 0 {
+				delta.Enter = append(delta.Enter, tid)
+			} else if n < 0 {
+				delta.Leave = append(delta.Leave, tid)
+			}
+		}
+		if len(delta.Enter) == 0 && len(delta.Leave) == 0 {
+			continue
+		}
+		slices.Sort(delta.Enter)
+		slices.Sort(delta.Leave)
+		res = append(res, delta)
+	}
+	slices.SortFunc(res, func(a, b ViewDelta) int {
+		return cmp.Compare(a.Player, b.Player)
+	})
+	clear(b.net)
+	return res
+}
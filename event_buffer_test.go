@@ -0,0 +1,53 @@
+package aoi_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/3d"
+	"github.com/beijian128/aoi/aoitest"
+)
+
+func TestEventBuffer(t *testing.T) {
+	m := three_dim.NewManager()
+	buf := aoi.NewEventBuffer()
+	m.SetCallback(buf)
+	m.AddPlayer(1)
+	m.AddPlayer(2)
+	m.AddEntity(1, aoitest.At(0), 10)
+	m.AddEntity(2, aoitest.At(100), 10)
+	m.Subscribe(1, 1)
+	m.Subscribe(2, 2)
+	m.AddEntity(3, aoitest.At(5), 0)
+	m.AddEntity(4, aoitest.At(6), 0)
+	m.AddEntity(5, aoitest.At(95), 0)
+
+	// 第一个 tick: 3 进入又离开，被抵消
+	m.MoveEntity(3, aoitest.At(50))
+	got := buf.Flush()
+	want := []aoi.ViewDelta{
+		{Player: 1, Enter: []aoi.EntityID{4}},
+		{Player: 2, Enter: []aoi.EntityID{5}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("first flush = %+v, want %+v", got, want)
+	}
+
+	// 第二个 tick: 4 离开又回来也被抵消，5 从玩家 2 转到玩家 1
+	m.MoveEntity(4, aoitest.At(60))
+	m.MoveEntity(4, aoitest.At(4))
+	m.MoveEntity(5, aoitest.At(8))
+	got = buf.Flush()
+	want = []aoi.ViewDelta{
+		{Player: 1, Enter: []aoi.EntityID{5}},
+		{Player: 2, Leave: []aoi.EntityID{5}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("second flush = %+v, want %+v", got, want)
+	}
+
+	if got := buf.Flush(); len(got) != 0 {
+		t.Fatalf("empty tick flush = %+v", got)
+	}
+}
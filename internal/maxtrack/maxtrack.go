@@ -0,0 +1,43 @@
+// Package maxtrack 各个管理器共用的最大值统计 (最大视野半径、最大包围半径)
+package maxtrack
+
+import (
+	"github.com/beijian128/aoi"
+)
+
+// Tracker 一组值的最大值，以及取到最大值的成员个数
+// 只统计正数，没有正数时最大值为 0 (视野半径、包围半径为 0 的实体不影响扫描范围)
+// 成员离开时只有最后一个取到最大值的成员离开才需要重新统计，所有成员的值都相同时 (最常见的情况) 移除是 O(1)
+type Tracker struct {
+	max   aoi.Float
+	count int
+}
+
+// Max 当前的最大值，没有成员时为 0
+func (t *Tracker) Max() aoi.Float {
+	return t.max
+}
+
+// Add 加入一个值
+func (t *Tracker) Add(v aoi.Float) {
+	switch {
+	case v > t.max:
+		t.max, t.count = v, 1
+	case v == t.max && v > 0:
+		t.count++
+	}
+}
+
+// Remove 移除一个之前加入的值，返回 true 表示最大值已经没有成员，调用方需要 Reset 之后重新 Add 所有成员
+func (t *Tracker) Remove(v aoi.Float) bool {
+	if v != t.max || t.count == 0 {
+		return false
+	}
+	t.count--
+	return t.count == 0
+}
+
+// Reset 清空
+func (t *Tracker) Reset() {
+	t.max, t.count = 0, 0
+}
@@ -0,0 +1,47 @@
+// Package players 各个管理器共用的玩家管理 (创建玩家、移除玩家时取消订阅)
+package players
+
+import (
+	"github.com/beijian128/aoi"
+)
+
+// New 创建玩家 id
+func New(id aoi.PlayerID) *aoi.Player {
+	return &aoi.Player{
+		ID:            id,
+		FinalView:     make(map[aoi.EntityID]int),
+		Subscriptions: aoi.NewSet[aoi.EntityID](),
+	}
+}
+
+// Drop 各个管理器 RemovePlayer 的通用实现：从 players 中删除玩家 id，对它订阅的每个实体调用 unsubscribe 取消订阅，
+// emitLeave 为 true 时对视野内的所有目标 (包括宽限期中的) 触发 cb.OnLeave
+// 只访问玩家订阅的实体，开销与实体总数无关
+func Drop[E any](players map[aoi.PlayerID]*aoi.Player, entities map[aoi.EntityID]E, id aoi.PlayerID, emitLeave bool, cb aoi.AOICallback, unsubscribe func(e E)) {
+	player := players[id]
+	if player == nil {
+		return
+	}
+	for eid := range player.Subscriptions {
+		if e, ok := entities[eid]; ok {
+			unsubscribe(e)
+		}
+	}
+	delete(players, id)
+	if !emitLeave || cb == nil {
+		return
+	}
+	for eid := range player.FinalView {
+		cb.OnLeave(id, eid)
+	}
+	for eid := range player.PendingLeave {
+		cb.OnLeave(id, eid)
+	}
+}
+
+// DropEntity 实体 id 被移除时，从订阅它的玩家的订阅列表中删掉它
+func DropEntity(subscribers map[aoi.PlayerID]*aoi.Player, id aoi.EntityID) {
+	for _, p := range subscribers {
+		p.Subscriptions.Remove(id)
+	}
+}
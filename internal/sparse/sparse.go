@@ -0,0 +1,102 @@
+// Package sparse 2D 稀疏网格与 3D 空间哈希共用的稀疏格子 (格子坐标与遍历)
+package sparse
+
+import (
+	"math"
+
+	"github.com/beijian128/aoi"
+)
+
+// maxCellCoord 稀疏格子坐标的上限，更远的位置夹到这里 (坐标本身的精度早已不够用了)
+const maxCellCoord = 1 << 52
+
+// Key 稀疏格子的坐标，按 X/Z 划分 (2D) 时第三个分量总是 0
+type Key [3]int64
+
+// Cells 按格子坐标哈希的稀疏格子，没有地图范围
+// 格子由调用方在第一个实体进入时放进来、最后一个实体离开时释放，内存只与实体数有关
+type Cells[C any] struct {
+	size  float64
+	dims  int
+	cells map[Key]*C
+}
+
+// New 创建大小为 size 的稀疏格子，dims 为 2 时按 X/Z 划分，为 3 时按 X/Y/Z 划分
+func New[C any](size aoi.Float, dims int) *Cells[C] {
+	return &Cells[C]{
+		size:  float64(size),
+		dims:  dims,
+		cells: make(map[Key]*C),
+	}
+}
+
+// Size 格子大小
+func (s *Cells[C]) Size() aoi.Float {
+	return aoi.Float(s.size)
+}
+
+// Len 现有的格子数
+func (s *Cells[C]) Len() int {
+	return len(s.cells)
+}
+
+// coord 坐标 v 所在格子的坐标，向下取整
+func (s *Cells[C]) coord(v aoi.Float) int64 {
+	c := math.Floor(float64(v) / s.size)
+	switch {
+	case math.IsNaN(c): // 距离判定总是失败，放在哪里都一样
+		return 0
+	case c > maxCellCoord:
+		return maxCellCoord
+	case c < -maxCellCoord:
+		return -maxCellCoord
+	}
+	return int64(c)
+}
+
+// KeyOf pos 所在格子的坐标
+func (s *Cells[C]) KeyOf(pos aoi.Position) Key {
+	if s.dims == 2 {
+		return Key{s.coord(pos.X), s.coord(pos.Z)}
+	}
+	return Key{s.coord(pos.X), s.coord(pos.Y), s.coord(pos.Z)}
+}
+
+// Get key 处的格子，不存在时为 nil
+func (s *Cells[C]) Get(key Key) *C {
+	return s.cells[key]
+}
+
+// Put 放进 key 处的格子
+func (s *Cells[C]) Put(key Key, c *C) {
+	s.cells[key] = c
+}
+
+// Release 释放 key 处的格子
+func (s *Cells[C]) Release(key Key) {
+	delete(s.cells, key)
+}
+
+// ForEachInBox 遍历以 pos 为中心、半边长为 r 的正方形 (按 X/Y/Z 划分时为立方体) 覆盖的现有格子
+func (s *Cells[C]) ForEachInBox(pos aoi.Position, r aoi.Float, fn func(c *C)) {
+	lo := s.KeyOf(aoi.Position{X: pos.X - r, Y: pos.Y - r, Z: pos.Z - r})
+	hi := s.KeyOf(aoi.Position{X: pos.X + r, Y: pos.Y + r, Z: pos.Z + r})
+	// 覆盖的格子比现有的格子还多时，直接遍历现有的格子
+	if float64(hi[0]-lo[0]+1)*float64(hi[1]-lo[1]+1)*float64(hi[2]-lo[2]+1) > float64(len(s.cells)) {
+		for key, c := range s.cells {
+			if key[0] >= lo[0] && key[0] <= hi[0] && key[1] >= lo[1] && key[1] <= hi[1] && key[2] >= lo[2] && key[2] <= hi[2] {
+				fn(c)
+			}
+		}
+		return
+	}
+	for a := lo[0]; a <= hi[0]; a++ {
+		for b := lo[1]; b <= hi[1]; b++ {
+			for c := lo[2]; c <= hi[2]; c++ {
+				if cell := s.cells[Key{a, b, c}]; cell != nil {
+					fn(cell)
+				}
+			}
+		}
+	}
+}
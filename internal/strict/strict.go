@@ -0,0 +1,145 @@
+// Package strict 严格模式的通用实现，各个管理器只需要提供 ID 与坐标校验
+package strict
+
+import (
+	"fmt"
+
+	"github.com/beijian128/aoi"
+)
+
+// Checks 严格模式需要被包装的管理器提供的查询
+type Checks interface {
+	HasPlayer(id aoi.PlayerID) bool
+	HasEntity(id aoi.EntityID) bool
+	// ValidPos 非 nil 的位置能否使用，返回 false 时严格模式返回 aoi.ErrOutOfBounds
+	ValidPos(pos *aoi.Position) bool
+}
+
+// manager 严格模式，参数校验通过之后再交给 m 执行
+type manager struct {
+	m aoi.AOIManager
+	c Checks
+}
+
+// New 返回 m 的严格模式视图，与 m 共享同一份数据，各个管理器只需要提供 Checks
+func New(m aoi.AOIManager, c Checks) aoi.StrictAOIManager {
+	return manager{m: m, c: c}
+}
+
+func (s manager) AddPlayer(id aoi.PlayerID) error {
+	if s.c.HasPlayer(id) {
+		return fmt.Errorf("%w: %d", aoi.ErrDuplicatePlayer, id)
+	}
+	s.m.AddPlayer(id)
+	return nil
+}
+
+func (s manager) RemovePlayer(id aoi.PlayerID, emitLeave bool) error {
+	if err := s.checkPlayer(id); err != nil {
+		return err
+	}
+	s.m.RemovePlayer(id, emitLeave)
+	return nil
+}
+
+func (s manager) AddEntity(id aoi.EntityID, pos *aoi.Position, rangeVal aoi.Float) error {
+	if s.c.HasEntity(id) {
+		return fmt.Errorf("%w: %d", aoi.ErrDuplicateEntity, id)
+	}
+	if err := s.checkPos(pos); err != nil {
+		return err
+	}
+	s.m.AddEntity(id, pos, rangeVal)
+	return nil
+}
+
+func (s manager) RemoveEntity(id aoi.EntityID) error {
+	if err := s.checkEntity(id); err != nil {
+		return err
+	}
+	s.m.RemoveEntity(id)
+	return nil
+}
+
+func (s manager) MoveEntity(id aoi.EntityID, pos *aoi.Position) error {
+	if err := s.checkEntity(id); err != nil {
+		return err
+	}
+	if err := s.checkPos(pos); err != nil {
+		return err
+	}
+	s.m.MoveEntity(id, pos)
+	return nil
+}
+
+func (s manager) SetRange(id aoi.EntityID, r aoi.Float) error {
+	if err := s.checkEntity(id); err != nil {
+		return err
+	}
+	s.m.SetRange(id, r)
+	return nil
+}
+
+func (s manager) GetView(id aoi.PlayerID) (aoi.Set[aoi.EntityID], error) {
+	if err := s.checkPlayer(id); err != nil {
+		return nil, err
+	}
+	return s.m.GetView(id), nil
+}
+
+func (s manager) CanSee(watcherId aoi.PlayerID, targetId aoi.EntityID) (bool, error) {
+	if err := s.checkPlayer(watcherId); err != nil {
+		return false, err
+	}
+	return s.m.CanSee(watcherId, targetId), nil
+}
+
+func (s manager) Subscribe(subscriber aoi.PlayerID, target aoi.EntityID) error {
+	if err := s.checkPlayer(subscriber); err != nil {
+		return err
+	}
+	if err := s.checkEntity(target); err != nil {
+		return err
+	}
+	s.m.Subscribe(subscriber, target)
+	return nil
+}
+
+func (s manager) Unsubscribe(subscriber aoi.PlayerID, target aoi.EntityID) error {
+	if err := s.checkPlayer(subscriber); err != nil {
+		return err
+	}
+	if err := s.checkEntity(target); err != nil {
+		return err
+	}
+	s.m.Unsubscribe(subscriber, target)
+	return nil
+}
+
+func (s manager) SetCallback(cb aoi.AOICallback) {
+	s.m.SetCallback(cb)
+}
+
+func (s manager) checkPlayer(id aoi.PlayerID) error {
+	if !s.c.HasPlayer(id) {
+		return fmt.Errorf("%w: %d", aoi.ErrPlayerNotFound, id)
+	}
+	return nil
+}
+
+func (s manager) checkEntity(id aoi.EntityID) error {
+	if !s.c.HasEntity(id) {
+		return fmt.Errorf("%w: %d", aoi.ErrEntityNotFound, id)
+	}
+	return nil
+}
+
+func (s manager) checkPos(pos *aoi.Position) error {
+	if pos == nil {
+		return aoi.ErrNilPosition
+	}
+	if !s.c.ValidPos(pos) {
+		return fmt.Errorf("%w: (%v, %v, %v)", aoi.ErrOutOfBounds, pos.X, pos.Y, pos.Z)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// Package view 各个管理器共用的玩家视野维护：引用计数、回调投递与延迟 Leave
+package view
+
+import (
+	"time"
+
+	"github.com/beijian128/aoi"
+)
+
+// Grace 延迟 Leave 的时钟与宽限期，零值表示不延迟
+type Grace struct {
+	clock aoi.Clock
+	d     time.Duration
+}
+
+// Set 设置时钟与宽限期 (aoi.GraceManager 的 SetLeaveGrace)，clock 为 nil 时使用 aoi.SystemClock，
+// d <= 0 时关闭并立即投递所有推迟的 Leave
+func (g *Grace) Set(players map[aoi.PlayerID]*aoi.Player, cb aoi.AOICallback, clock aoi.Clock, d time.Duration) {
+	if clock == nil {
+		clock = aoi.SystemClock{}
+	}
+	g.clock = clock
+	g.d = max(d, 0)
+	if g.d == 0 {
+		g.flush(players, cb, true)
+	}
+}
+
+// Tick 投递所有已经到期的 Leave
+func (g *Grace) Tick(players map[aoi.PlayerID]*aoi.Player, cb aoi.AOICallback) {
+	if g.clock != nil {
+		g.flush(players, cb, false)
+	}
+}
+
+// flush 投递到期 (all 为 true 时为全部) 的延迟 Leave
+func (g *Grace) flush(players map[aoi.PlayerID]*aoi.Player, cb aoi.AOICallback, all bool) {
+	var now time.Time
+	if !all {
+		now = g.clock.Now()
+	}
+	for _, p := range players {
+		for tid, deadline := range p.PendingLeave {
+			if all || !now.Before(deadline) {
+				delete(p.PendingLeave, tid)
+				if cb != nil {
+					cb.OnLeave(p.ID, tid)
+				}
+			}
+		}
+	}
+}
+
+// Change 玩家 p 对 targetID 的引用计数加上 delta，0 -> 正数时触发 Enter，正数 -> 0 时触发 Leave
+// g 不为 nil 且开启了宽限期时 Leave 推迟到期再投递，期间回到视野则取消这次 Leave (也不会再次 Enter)
+func Change(p *aoi.Player, targetID aoi.EntityID, delta int, cb aoi.AOICallback, g *Grace) {
+	oldVal := p.FinalView[targetID]
+	newVal := oldVal + delta
+	if newVal <= 0 {
+		delete(p.FinalView, targetID)
+	} else {
+		p.FinalView[targetID] = newVal
+	}
+
+	switch {
+	case oldVal == 0 && newVal > 0:
+		if _, ok := p.PendingLeave[targetID]; ok {
+			// 宽限期内回到视野，取消 Leave，客户端那边一直是可见的
+			delete(p.PendingLeave, targetID)
+			return
+		}
+		if cb != nil {
+			cb.OnEnter(p.ID, targetID)
+		}
+	case oldVal > 0 && newVal <= 0:
+		if g != nil && g.d > 0 {
+			if p.PendingLeave == nil {
+				p.PendingLeave = make(map[aoi.EntityID]time.Time)
+			}
+			p.PendingLeave[targetID] = g.clock.Now().Add(g.d)
+			return
+		}
+		if cb != nil {
+			cb.OnLeave(p.ID, targetID)
+		}
+	}
+}
+
+// Notify 实体能否看见 targetID 发生了变化 (delta 为 1 或 -1)，对订阅了这个实体的每个玩家调用 Change
+func Notify(subscribers map[aoi.PlayerID]*aoi.Player, targetID aoi.EntityID, delta int, cb aoi.AOICallback, g *Grace) {
+	for _, p := range subscribers {
+		Change(p, targetID, delta, cb, g)
+	}
+}
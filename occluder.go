@@ -0,0 +1,135 @@
+package aoi
+
+// Occluder 遮挡物：判断视线是否被挡住
+// 管理器只对通过了粗筛 (以及形状、朝向判定) 的实体对做视线检测
+type Occluder interface {
+	// Blocks 从 from 到 to 的视线是否被遮挡
+	Blocks(from, to Position) bool
+}
+
+// OcclusionManager 支持视线遮挡的管理器
+type OcclusionManager interface {
+	AOIManager
+	// SetOccluder 设置遮挡物 (通常是 *ObstacleLayer)，nil 表示没有遮挡
+	// 设置之后会重新判定所有视野关系
+	SetOccluder(o Occluder)
+}
+
+// Segment2D XZ 平面上的一段墙，在 3D 场景中视为无限高的竖直墙面
+type Segment2D struct {
+	A, B Position
+}
+
+func (s Segment2D) Blocks(from, to Position) bool {
+	return segmentsIntersectXZ(from, to, s.A, s.B)
+}
+
+// Polygon2D XZ 平面上的实心多边形 (顶点按顺序首尾相连)，在 3D 场景中视为无限高的柱体
+type Polygon2D struct {
+	Points []Position
+}
+
+func (p Polygon2D) Blocks(from, to Position) bool {
+	n := len(p.Points)
+	for i := 0; i < n; i++ {
+		if segmentsIntersectXZ(from, to, p.Points[i], p.Points[(i+1)%n]) {
+			return true
+		}
+	}
+	// 视线完全在多边形内部
+	return n >= 3 && p.containsXZ(from)
+}
+
+// containsXZ 点是否在多边形内 (射线法)
+func (p Polygon2D) containsXZ(pt Position) bool {
+	in := false
+	for i, j := 0, len(p.Points)-1; i < len(p.Points); j, i = i, i+1 {
+		a, b := p.Points[i], p.Points[j]
+		if (a.Z > pt.Z) != (b.Z > pt.Z) && pt.X < (b.X-a.X)*(pt.Z-a.Z)/(b.Z-a.Z)+a.X {
+			in = !in
+		}
+	}
+	return in
+}
+
+// Box3D 轴对齐的实心长方体，Min/Max 是世界坐标
+type Box3D struct {
+	Min, Max Position
+}
+
+// Blocks 线段与长方体求交 (slab 法)
+func (b Box3D) Blocks(from, to Position) bool {
+	f := [3]Float{from.X, from.Y, from.Z}
+	d := [3]Float{to.X - from.X, to.Y - from.Y, to.Z - from.Z}
+	lo := [3]Float{b.Min.X, b.Min.Y, b.Min.Z}
+	hi := [3]Float{b.Max.X, b.Max.Y, b.Max.Z}
+	tMin, tMax := Float(0), Float(1)
+	for axis := 0; axis < 3; axis++ {
+		if d[axis] == 0 {
+			if f[axis] < lo[axis] || f[axis] > hi[axis] {
+				return false
+			}
+			continue
+		}
+		t1 := (lo[axis] - f[axis]) / d[axis]
+		t2 := (hi[axis] - f[axis]) / d[axis]
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		tMin = max(tMin, t1)
+		tMax = min(tMax, t2)
+		if tMin > tMax {
+			return false
+		}
+	}
+	return true
+}
+
+// ObstacleLayer 静态遮挡物集合，任何一个遮挡物挡住视线即视为被遮挡
+type ObstacleLayer struct {
+	occluders []Occluder
+}
+
+func NewObstacleLayer(occluders ...Occluder) *ObstacleLayer {
+	return &ObstacleLayer{occluders: occluders}
+}
+
+// Add 添加遮挡物，已经设置到管理器上的图层需要重新 SetOccluder 才会生效
+func (l *ObstacleLayer) Add(o Occluder) {
+	l.occluders = append(l.occluders, o)
+}
+
+func (l *ObstacleLayer) Blocks(from, to Position) bool {
+	for _, o := range l.occluders {
+		if o.Blocks(from, to) {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentsIntersectXZ 线段 p1p2 与 q1q2 在 XZ 平面上是否相交 (端点接触、共线重叠都算相交)
+func segmentsIntersectXZ(p1, p2, q1, q2 Position) bool {
+	d1 := crossXZ(q1, q2, p1)
+	d2 := crossXZ(q1, q2, p2)
+	d3 := crossXZ(p1, p2, q1)
+	d4 := crossXZ(p1, p2, q2)
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return (d1 == 0 && onSegmentXZ(q1, q2, p1)) ||
+		(d2 == 0 && onSegmentXZ(q1, q2, p2)) ||
+		(d3 == 0 && onSegmentXZ(p1, p2, q1)) ||
+		(d4 == 0 && onSegmentXZ(p1, p2, q2))
+}
+
+// crossXZ (b-a) x (c-a) 在 XZ 平面上的叉积
+func crossXZ(a, b, c Position) Float {
+	return (b.X-a.X)*(c.Z-a.Z) - (b.Z-a.Z)*(c.X-a.X)
+}
+
+// onSegmentXZ 已知 c 与 ab 共线，c 是否落在 ab 上
+func onSegmentXZ(a, b, c Position) bool {
+	return min(a.X, b.X) <= c.X && c.X <= max(a.X, b.X) &&
+		min(a.Z, b.Z) <= c.Z && c.Z <= max(a.Z, b.Z)
+}
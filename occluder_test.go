@@ -0,0 +1,41 @@
+package aoi_test
+
+import (
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestOccluders(t *testing.T) {
+	p := func(x, y, z aoi.Float) aoi.Position { return aoi.Position{X: x, Y: y, Z: z} }
+	wall := aoi.Segment2D{A: p(5, 0, -10), B: p(5, 0, 10)}
+	square := aoi.Polygon2D{Points: []aoi.Position{p(4, 0, -1), p(6, 0, -1), p(6, 0, 1), p(4, 0, 1)}}
+	box := aoi.Box3D{Min: p(4, 0, -1), Max: p(6, 3, 1)}
+
+	cases := []struct {
+		name     string
+		o        aoi.Occluder
+		from, to aoi.Position
+		want     bool
+	}{
+		{"wall crossed", wall, p(0, 0, 0), p(10, 0, 0), true},
+		{"wall same side", wall, p(0, 0, 0), p(4, 0, 5), false},
+		{"wall passes by the end", wall, p(0, 0, 20), p(10, 0, 12), false},
+		{"wall touched", wall, p(0, 0, 0), p(5, 0, 0), true},
+		{"wall ignores height", wall, p(0, 100, 0), p(10, 100, 0), true},
+		{"polygon crossed", square, p(0, 0, 0), p(10, 0, 0), true},
+		{"polygon missed", square, p(0, 0, 2), p(10, 0, 2), false},
+		{"polygon inside", square, p(4.5, 0, 0), p(5.5, 0, 0), true},
+		{"box crossed", box, p(0, 1, 0), p(10, 1, 0), true},
+		{"box over the top", box, p(0, 5, 0), p(10, 5, 0), false},
+		{"box diagonal", box, p(0, 0, 0), p(10, 6, 0), true},
+		{"box parallel outside", box, p(0, 1, 2), p(10, 1, 2), false},
+		{"layer", aoi.NewObstacleLayer(box, square), p(0, 5, 0), p(10, 5, 0), true},
+		{"empty layer", aoi.NewObstacleLayer(), p(0, 0, 0), p(10, 0, 0), false},
+	}
+	for _, c := range cases {
+		if got := c.o.Blocks(c.from, c.to); got != c.want {
+			t.Errorf("%s: Blocks = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package quadtree
+
+import (
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/internal/maxtrack"
+	"github.com/beijian128/aoi/internal/players"
+	"github.com/beijian128/aoi/internal/view"
+)
+
+type Entity struct {
+	id       aoi.EntityID
+	pos      *aoi.Position
+	rangeVal aoi.Float // 视野半径 (XZ 平面上的圆)
+	node     *node     // 所在叶子节点
+
+	subscribers map[aoi.PlayerID]*aoi.Player
+
+	// visible 我当前能看见的实体
+	visible aoi.Set[*Entity]
+	// watchers 当前能看见我的实体 (visible 的反向索引)
+	watchers aoi.Set[*Entity]
+}
+
+func (e *Entity) GetID() aoi.EntityID {
+	return e.id
+}
+
+func (e *Entity) GetPos() *aoi.Position {
+	return e.pos
+}
+
+func (e *Entity) GetRange() aoi.Float {
+	return e.rangeVal
+}
+
+// Manager 基于自适应四叉树的 AOI 管理器
+// 叶子节点实体数超过 capacity 时分裂，子树实体数不超过 capacity/2 时合并，
+// 适合大片空旷、局部密集的稀疏地图
+type Manager struct {
+	root     *node
+	capacity int
+	entities map[aoi.EntityID]*Entity
+	players  map[aoi.PlayerID]*aoi.Player
+
+	// maxRange 所有实体中最大的视野半径
+	maxRange maxtrack.Tracker
+
+	cbk aoi.AOICallback
+}
+
+// NewManager 创建四叉树管理器
+// [minX,maxX]x[minZ,maxZ] 只决定初始的划分方式，超出范围的实体同样可以正常工作
+func NewManager(minX, minZ, maxX, maxZ aoi.Float, capacity int) *Manager {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Manager{
+		root:     newNode(nil, 0, (minX+maxX)/2, (minZ+maxZ)/2, (maxX-minX)/2, (maxZ-minZ)/2),
+		capacity: capacity,
+		entities: make(map[aoi.EntityID]*Entity),
+		players:  make(map[aoi.PlayerID]*aoi.Player),
+	}
+}
+
+func (m *Manager) SetCallback(cb aoi.AOICallback) {
+	m.cbk = cb
+}
+
+func (m *Manager) AddPlayer(id aoi.PlayerID) {
+	if _, ok := m.players[id]; !ok {
+		m.players[id] = players.New(id)
+	}
+}
+
+// RemovePlayer 移除玩家 (例如客户端断线)，取消它对所有实体的订阅
+// emitLeave 为 true 时对视野内的所有目标触发 OnLeave
+func (m *Manager) RemovePlayer(id aoi.PlayerID, emitLeave bool) {
+	players.Drop(m.players, m.entities, id, emitLeave, m.cbk, func(e *Entity) {
+		delete(e.subscribers, id)
+	})
+}
+
+func (m *Manager) AddEntity(id aoi.EntityID, pos *aoi.Position, rangeVal aoi.Float) {
+	if pos == nil {
+		return
+	}
+	if _, ok := m.entities[id]; ok {
+		return
+	}
+	e := &Entity{
+		id:          id,
+		pos:         pos,
+		rangeVal:    rangeVal,
+		subscribers: make(map[aoi.PlayerID]*aoi.Player),
+		visible:     aoi.NewSet[*Entity](),
+		watchers:    aoi.NewSet[*Entity](),
+	}
+	m.root.insert(e, m.capacity)
+	m.entities[id] = e
+	m.maxRange.Add(rangeVal)
+	m.refresh(e)
+}
+
+func (m *Manager) RemoveEntity(id aoi.EntityID) {
+	e := m.entities[id]
+	if e == nil {
+		return
+	}
+	m.root.remove(e, m.capacity)
+	delete(m.entities, id)
+	players.DropEntity(e.subscribers, id)
+	e.visible.ForEach(func(other *Entity) bool {
+		m.leave(e, other)
+		return false
+	})
+	e.watchers.ForEach(func(other *Entity) bool {
+		m.leave(other, e)
+		return false
+	})
+	if m.maxRange.Remove(e.rangeVal) {
+		m.resetMaxRange()
+	}
+}
+
+func (m *Manager) MoveEntity(id aoi.EntityID, pos *aoi.Position) {
+	if pos == nil {
+		return
+	}
+	e := m.entities[id]
+	if e == nil {
+		return
+	}
+	if m.root.leafFor(pos) != e.node {
+		m.root.remove(e, m.capacity)
+		e.pos = pos
+		m.root.insert(e, m.capacity)
+	} else {
+		e.pos = pos
+	}
+	m.refresh(e)
+}
+
+// SetRange 修改视野半径，只会影响 e 作为观察者的视野
+func (m *Manager) SetRange(id aoi.EntityID, r aoi.Float) {
+	e := m.entities[id]
+	if e == nil {
+		return
+	}
+	old := e.rangeVal
+	e.rangeVal = r
+	m.maxRange.Add(r)
+	if m.maxRange.Remove(old) {
+		m.resetMaxRange()
+	}
+	candidates := aoi.NewSet[*Entity]()
+	m.root.query(e.pos.X-r, e.pos.Z-r, e.pos.X+r, e.pos.Z+r, func(other *Entity) {
+		candidates.Add(other)
+	})
+	e.visible.ForEach(func(other *Entity) bool {
+		candidates.Add(other)
+		return false
+	})
+	candidates.ForEach(func(other *Entity) bool {
+		m.updatePair(e, other)
+		return false
+	})
+}
+
+func (m *Manager) GetView(id aoi.PlayerID) aoi.Set[aoi.EntityID] {
+	set := aoi.NewSet[aoi.EntityID]()
+	player := m.players[id]
+	if player == nil {
+		return set
+	}
+	for eid, cnt := range player.FinalView {
+		if cnt > 0 {
+			set.Add(eid)
+		}
+	}
+	return set
+}
+
+func (m *Manager) CanSee(watcherId aoi.PlayerID, targetId aoi.EntityID) bool {
+	watcher := m.players[watcherId]
+	if watcher == nil {
+		return false
+	}
+	return watcher.FinalView[targetId] > 0
+}
+
+func (m *Manager) Subscribe(subscriberId aoi.PlayerID, targetId aoi.EntityID) {
+	subscriber := m.players[subscriberId]
+	target := m.entities[targetId]
+	if subscriber == nil || target == nil {
+		return
+	}
+	if _, ok := target.subscribers[subscriberId]; ok { // 已经订阅过
+		return
+	}
+	target.subscribers[subscriberId] = subscriber
+	subscriber.Subscriptions.Add(targetId)
+	target.visible.ForEach(func(other *Entity) bool {
+		view.Change(subscriber, other.id, 1, m.cbk, nil)
+		return false
+	})
+}
+
+func (m *Manager) Unsubscribe(subscriberId aoi.PlayerID, targetId aoi.EntityID) {
+	subscriber := m.players[subscriberId]
+	target := m.entities[targetId]
+	if subscriber == nil || target == nil {
+		return
+	}
+	if _, ok := target.subscribers[subscriberId]; !ok { // 本来就没订阅
+		return
+	}
+	delete(target.subscribers, subscriberId)
+	subscriber.Subscriptions.Remove(targetId)
+	target.visible.ForEach(func(other *Entity) bool {
+		view.Change(subscriber, other.id, -1, m.cbk, nil)
+		return false
+	})
+}
+
+// refresh 重新判定 e 与周围实体之间 (双向) 的可见性
+func (m *Manager) refresh(e *Entity) {
+	radius := e.rangeVal
+	if m.maxRange.Max() > radius {
+		radius = m.maxRange.Max()
+	}
+	candidates := aoi.NewSet[*Entity]()
+	m.root.query(e.pos.X-radius, e.pos.Z-radius, e.pos.X+radius, e.pos.Z+radius, func(other *Entity) {
+		if other != e {
+			candidates.Add(other)
+		}
+	})
+	// 原本有视野关系但已经跑出扫描范围的实体，也要参与判定
+	e.visible.ForEach(func(other *Entity) bool {
+		candidates.Add(other)
+		return false
+	})
+	e.watchers.ForEach(func(other *Entity) bool {
+		candidates.Add(other)
+		return false
+	})
+	candidates.ForEach(func(other *Entity) bool {
+		m.updatePair(e, other)
+		m.updatePair(other, e)
+		return false
+	})
+}
+
+// updatePair 根据当前距离更新 watcher 对 target 的可见性，并在变化时通知订阅者
+func (m *Manager) updatePair(watcher, target *Entity) {
+	want := inRange(watcher, target)
+	has := watcher.visible.Contains(target)
+	if want && !has {
+		m.enter(watcher, target)
+	} else if !want && has {
+		m.leave(watcher, target)
+	}
+}
+
+// inRange target 是否处于 watcher 的视野圆内
+func inRange(watcher, target *Entity) bool {
+	if watcher == target {
+		return false
+	}
+	dx := target.pos.X - watcher.pos.X
+	dz := target.pos.Z - watcher.pos.Z
+	return dx*dx+dz*dz <= watcher.rangeVal*watcher.rangeVal
+}
+
+// resetMaxRange 重新统计最大视野半径
+func (m *Manager) resetMaxRange() {
+	m.maxRange.Reset()
+	for _, e := range m.entities {
+		m.maxRange.Add(e.rangeVal)
+	}
+}
+
+// enter watcher 看见了 target
+func (m *Manager) enter(watcher, target *Entity) {
+	watcher.visible.Add(target)
+	target.watchers.Add(watcher)
+	view.Notify(watcher.subscribers, target.id, 1, m.cbk, nil)
+}
+
+// leave watcher 看不见 target 了
+func (m *Manager) leave(watcher, target *Entity) {
+	watcher.visible.Remove(target)
+	target.watchers.Remove(watcher)
+	view.Notify(watcher.subscribers, target.id, -1, m.cbk, nil)
+}
@@ -0,0 +1,50 @@
+package quadtree
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/beijian128/aoi"
+)
+
+func TestSplitAndMerge(t *testing.T) {
+	m := NewManager(0, 0, 1000, 1000, 4)
+	for i := 0; i < 64; i++ {
+		m.AddEntity(aoi.EntityID(i), &aoi.Position{X: aoi.Float(i%8) * 10, Z: aoi.Float(i/8) * 10}, 5)
+	}
+	if m.root.isLeaf() {
+		t.Fatal("root should split once capacity is exceeded")
+	}
+	if m.root.count != 64 {
+		t.Fatalf("root count = %d, want 64", m.root.count)
+	}
+	for i := 0; i < 62; i++ {
+		m.RemoveEntity(aoi.EntityID(i))
+	}
+	if !m.root.isLeaf() || len(m.root.entities) != 2 {
+		t.Fatal("tree should merge back into a single leaf")
+	}
+}
+
+func TestVisibilityMatchesDistance(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	m := NewManager(0, 0, 500, 500, 4)
+	randPos := func() *aoi.Position {
+		// 故意让一部分实体落在初始范围之外
+		return &aoi.Position{X: aoi.Float(rnd.Float64()*700 - 100), Z: aoi.Float(rnd.Float64()*700 - 100)}
+	}
+	for i := 0; i < 100; i++ {
+		m.AddEntity(aoi.EntityID(i), randPos(), aoi.Float(rnd.Float64()*80))
+	}
+	for step := 0; step < 2000; step++ {
+		id := aoi.EntityID(rnd.Intn(100))
+		m.MoveEntity(id, randPos())
+	}
+	for _, w := range m.entities {
+		for _, tgt := range m.entities {
+			if inRange(w, tgt) != w.visible.Contains(tgt) {
+				t.Fatalf("visibility of %d -> %d out of sync", w.id, tgt.id)
+			}
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package quadtree
+
+import (
+	"testing"
+
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/aoitest"
+)
+
+func TestConformance(t *testing.T) {
+	aoitest.RunConformance(t, func() aoi.AOIManager {
+		return NewManager(0, 0, 1000, 1000, 4)
+	})
+}
+
+func TestDifferential(t *testing.T) {
+	aoitest.RunDifferential(t, func() aoi.AOIManager {
+		return NewManager(490, 490, 520, 520, 2)
+	}, func() aoi.AOIManager {
+		return aoitest.NewOracle(aoitest.InCircleXZ)
+	}, 200, 300)
+}
@@ -0,0 +1,157 @@
+package quadtree
+
+import (
+	"github.com/beijian128/aoi"
+)
+
+// maxDepth 最大深度，防止大量实体重叠在同一点时无限分裂
+const maxDepth = 16
+
+// node 四叉树节点
+// 只有叶子节点持有实体；子节点按 (x >= midX) | (z >= midZ)<<1 编号
+// 超出根节点范围的实体会落入边缘的子节点，因此树本身没有边界限制
+type node struct {
+	parent       *node
+	depth        int
+	midX, midZ   aoi.Float
+	halfX, halfZ aoi.Float // 节点半宽
+
+	children *[4]*node
+	entities map[aoi.EntityID]*Entity
+	count    int // 子树中的实体数量
+}
+
+func newNode(parent *node, depth int, midX, midZ, halfX, halfZ aoi.Float) *node {
+	return &node{
+		parent:   parent,
+		depth:    depth,
+		midX:     midX,
+		midZ:     midZ,
+		halfX:    halfX,
+		halfZ:    halfZ,
+		entities: make(map[aoi.EntityID]*Entity),
+	}
+}
+
+func (n *node) isLeaf() bool {
+	return n.children == nil
+}
+
+func (n *node) childIndex(pos *aoi.Position) int {
+	idx := 0
+	if pos.X >= n.midX {
+		idx |= 1
+	}
+	if pos.Z >= n.midZ {
+		idx |= 2
+	}
+	return idx
+}
+
+// leafFor 找到 pos 所属的叶子节点
+func (n *node) leafFor(pos *aoi.Position) *node {
+	for !n.isLeaf() {
+		n = n.children[n.childIndex(pos)]
+	}
+	return n
+}
+
+// insert 将实体插入 pos 所属的叶子，超出容量时分裂
+func (n *node) insert(e *Entity, capacity int) {
+	leaf := n.leafFor(e.pos)
+	leaf.entities[e.id] = e
+	e.node = leaf
+	for p := leaf; p != nil; p = p.parent {
+		p.count++
+	}
+	if len(leaf.entities) > capacity && leaf.depth < maxDepth {
+		leaf.split(capacity)
+	}
+}
+
+// remove 将实体从所在叶子中移除，子树实体过少时合并
+func (n *node) remove(e *Entity, capacity int) {
+	leaf := e.node
+	delete(leaf.entities, e.id)
+	e.node = nil
+	for p := leaf; p != nil; p = p.parent {
+		p.count--
+	}
+	// 计数自下而上单调递增，找到最高的一个可合并节点即可
+	var target *node
+	for p := leaf.parent; p != nil && p.count <= capacity/2; p = p.parent {
+		target = p
+	}
+	if target != nil {
+		target.merge()
+	}
+}
+
+func (n *node) split(capacity int) {
+	hx, hz := n.halfX/2, n.halfZ/2
+	n.children = &[4]*node{
+		newNode(n, n.depth+1, n.midX-hx, n.midZ-hz, hx, hz),
+		newNode(n, n.depth+1, n.midX+hx, n.midZ-hz, hx, hz),
+		newNode(n, n.depth+1, n.midX-hx, n.midZ+hz, hx, hz),
+		newNode(n, n.depth+1, n.midX+hx, n.midZ+hz, hx, hz),
+	}
+	entities := n.entities
+	n.entities = nil
+	for _, e := range entities {
+		child := n.children[n.childIndex(e.pos)]
+		child.entities[e.id] = e
+		child.count++
+		e.node = child
+	}
+	// 所有实体可能都落在同一个子节点中，需要继续分裂
+	for _, child := range n.children {
+		if len(child.entities) > capacity && child.depth < maxDepth {
+			child.split(capacity)
+		}
+	}
+}
+
+func (n *node) merge() {
+	entities := make(map[aoi.EntityID]*Entity, n.count)
+	n.collect(entities)
+	n.children = nil
+	n.entities = entities
+	for _, e := range entities {
+		e.node = n
+	}
+}
+
+func (n *node) collect(out map[aoi.EntityID]*Entity) {
+	if n.isLeaf() {
+		for id, e := range n.entities {
+			out[id] = e
+		}
+		return
+	}
+	for _, child := range n.children {
+		child.collect(out)
+	}
+}
+
+// query 遍历与矩形 [minX,maxX]x[minZ,maxZ] 相交的叶子中的所有实体
+// 只是粗筛，调用方需要自己再做精确判定
+func (n *node) query(minX, minZ, maxX, maxZ aoi.Float, fn func(e *Entity)) {
+	if n.count == 0 {
+		return
+	}
+	if n.isLeaf() {
+		for _, e := range n.entities {
+			fn(e)
+		}
+		return
+	}
+	for i, child := range n.children {
+		if i&1 == 0 && minX >= n.midX || i&1 != 0 && maxX < n.midX {
+			continue
+		}
+		if i&2 == 0 && minZ >= n.midZ || i&2 != 0 && maxZ < n.midZ {
+			continue
+		}
+		child.query(minX, minZ, maxX, maxZ, fn)
+	}
+}
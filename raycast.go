@@ -0,0 +1,63 @@
+package aoi
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// RaycastHit 射线命中的实体，Dist 是起点到命中点的距离
+type RaycastHit struct {
+	ID   EntityID
+	Dist Float
+}
+
+// Raycaster 支持射线检测的管理器 (弹道命中、鼠标拾取等)
+// 实体按位置与包围半径视为一个球 (2D 为 XZ 平面上的圆)；只读，不会触发回调
+type Raycaster interface {
+	// SetBodyRadius 设置实体的包围半径，默认为 0 (一个点)；只用于射线检测，不影响视野
+	SetBodyRadius(id EntityID, r Float)
+	// Raycast 从 origin 沿 dir 方向、长度为 maxDist 的射线命中的所有实体，按命中距离从近到远排列 (距离相同时按 ID)
+	// dir 不需要是单位向量，为零向量时没有结果；起点在包围球内的实体命中距离为 0
+	Raycast(origin, dir Position, maxDist Float) []RaycastHit
+}
+
+// Ray 射线，Dir 是单位向量
+type Ray struct {
+	Origin, Dir Position
+}
+
+// NewRay 把 dir 归一化，dir 为零向量 (或含 NaN/Inf) 时返回 false
+func NewRay(origin, dir Position) (Ray, bool) {
+	l := Float(math.Sqrt(float64(dir.X*dir.X + dir.Y*dir.Y + dir.Z*dir.Z)))
+	if !(l > 0) || l.IsInf(1) {
+		return Ray{}, false
+	}
+	return Ray{Origin: origin, Dir: Position{X: dir.X / l, Y: dir.Y / l, Z: dir.Z / l}}, true
+}
+
+// Hit 射线与球心 center、半径 radius 的球的第一个交点到起点的距离，起点在球内时为 0
+func (r Ray) Hit(center Position, radius Float) (Float, bool) {
+	ox, oy, oz := center.X-r.Origin.X, center.Y-r.Origin.Y, center.Z-r.Origin.Z
+	// tc 球心在射线上的投影，d2 球心到射线所在直线距离的平方
+	tc := ox*r.Dir.X + oy*r.Dir.Y + oz*r.Dir.Z
+	d2 := max(ox*ox+oy*oy+oz*oz-tc*tc, 0)
+	if !(d2 <= radius*radius) {
+		return 0, false
+	}
+	h := Float(math.Sqrt(float64(radius*radius - d2)))
+	if tc+h < 0 { // 球整个在起点后面
+		return 0, false
+	}
+	return max(tc-h, 0), true
+}
+
+// SortHits 按命中距离从近到远排序，距离相同时按 ID
+func SortHits(hits []RaycastHit) {
+	slices.SortFunc(hits, func(a, b RaycastHit) int {
+		if c := cmp.Compare(a.Dist, b.Dist); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
@@ -0,0 +1,164 @@
+package aoi
+
+import (
+	"sync"
+)
+
+type syncEvent struct {
+	watcher PlayerID
+	target  EntityID
+	enter   bool
+}
+
+// SyncManager 并发安全的 AOIManager 包装
+// 读操作 (GetView/CanSee) 持读锁，可以与其它读操作并发；写操作持写锁，同一时刻只有一个写者。
+// 内部管理器产生的回调先进入队列，释放锁之后再按产生顺序投递给业务回调，
+// 因此回调中可以安全地再次调用本管理器 (包括写操作)。
+// 如果投递时有其它 goroutine 正在投递，事件会交给那个 goroutine 顺序投递，写操作本身不等待。
+type SyncManager struct {
+	mu    sync.RWMutex
+	inner AOIManager
+
+	qmu         sync.Mutex
+	cb          AOICallback
+	pending     []syncEvent
+	dispatching bool
+}
+
+func NewSyncManager(inner AOIManager) *SyncManager {
+	s := &SyncManager{inner: inner}
+	inner.SetCallback(syncCollector{s})
+	return s
+}
+
+// syncCollector 挂在内部管理器上，只负责把事件放进队列
+type syncCollector struct {
+	s *SyncManager
+}
+
+func (c syncCollector) OnEnter(watcherID PlayerID, targetID EntityID) {
+	c.s.push(syncEvent{watcher: watcherID, target: targetID, enter: true})
+}
+
+func (c syncCollector) OnLeave(watcherID PlayerID, targetID EntityID) {
+	c.s.push(syncEvent{watcher: watcherID, target: targetID, enter: false})
+}
+
+func (s *SyncManager) push(ev syncEvent) {
+	s.qmu.Lock()
+	s.pending = append(s.pending, ev)
+	s.qmu.Unlock()
+}
+
+// dispatch 在不持有任何锁的情况下投递队列中的事件
+func (s *SyncManager) dispatch() {
+	s.qmu.Lock()
+	if s.dispatching {
+		s.qmu.Unlock()
+		return
+	}
+	s.dispatching = true
+	finished := false
+	defer func() {
+		// 回调 panic 时交出投递权，剩下的事件留给之后的写操作投递
+		if !finished {
+			s.qmu.Lock()
+			s.dispatching = false
+			s.qmu.Unlock()
+		}
+	}()
+	for len(s.pending) > 0 {
+		events, cb := s.pending, s.cb
+		s.pending = nil
+		s.qmu.Unlock()
+		if cb != nil {
+			for _, ev := range events {
+				if ev.enter {
+					cb.OnEnter(ev.watcher, ev.target)
+				} else {
+					cb.OnLeave(ev.watcher, ev.target)
+				}
+			}
+		}
+		s.qmu.Lock()
+	}
+	// 检查队列与交出投递权在同一次加锁内完成，不会漏掉其它 goroutine 刚放进来的事件
+	s.dispatching = false
+	finished = true
+	s.qmu.Unlock()
+}
+
+// write 持写锁执行 fn，释放锁之后投递产生的事件
+func (s *SyncManager) write(fn func(m AOIManager)) {
+	s.locked(fn)
+	s.dispatch()
+}
+
+// locked 持写锁执行 fn，fn panic 时同样会释放锁
+func (s *SyncManager) locked(fn func(m AOIManager)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.inner)
+}
+
+// Update 在一次写锁内执行多个写操作
+func (s *SyncManager) Update(fn func(m AOIManager)) {
+	s.write(fn)
+}
+
+// Read 持读锁执行 fn，fn 中只能调用只读的方法 (例如生成调试快照)
+func (s *SyncManager) Read(fn func(m AOIManager)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fn(s.inner)
+}
+
+func (s *SyncManager) SetCallback(cb AOICallback) {
+	s.qmu.Lock()
+	s.cb = cb
+	s.qmu.Unlock()
+}
+
+func (s *SyncManager) AddPlayer(id PlayerID) {
+	s.write(func(m AOIManager) { m.AddPlayer(id) })
+}
+
+func (s *SyncManager) RemovePlayer(id PlayerID, emitLeave bool) {
+	s.write(func(m AOIManager) { m.RemovePlayer(id, emitLeave) })
+}
+
+func (s *SyncManager) AddEntity(id EntityID, pos *Position, rangeVal Float) {
+	s.write(func(m AOIManager) { m.AddEntity(id, pos, rangeVal) })
+}
+
+func (s *SyncManager) RemoveEntity(id EntityID) {
+	s.write(func(m AOIManager) { m.RemoveEntity(id) })
+}
+
+func (s *SyncManager) MoveEntity(id EntityID, pos *Position) {
+	s.write(func(m AOIManager) { m.MoveEntity(id, pos) })
+}
+
+func (s *SyncManager) SetRange(id EntityID, r Float) {
+	s.write(func(m AOIManager) { m.SetRange(id, r) })
+}
+
+func (s *SyncManager) Subscribe(subscriber PlayerID, target EntityID) {
+	s.write(func(m AOIManager) { m.Subscribe(subscriber, target) })
+}
+
+func (s *SyncManager) Unsubscribe(subscriber PlayerID, target EntityID) {
+	s.write(func(m AOIManager) { m.Unsubscribe(subscriber, target) })
+}
+
+func (s *SyncManager) GetView(id PlayerID) Set[EntityID] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.inner.GetView(id)
+}
+
+func (s *SyncManager) CanSee(watcherId PlayerID, targetId EntityID) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.inner.CanSee(watcherId, targetId)
+}
@@ -0,0 +1,135 @@
+package aoi_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/beijian128/aoi"
+	"github.com/beijian128/aoi/3d"
+	"github.com/beijian128/aoi/aoitest"
+)
+
+func TestSyncManagerConformance(t *testing.T) {
+	aoitest.RunConformance(t, func() aoi.AOIManager {
+		return aoi.NewSyncManager(three_dim.NewManager())
+	})
+}
+
+// reentrantCallback 在回调中再次调用管理器
+type reentrantCallback struct {
+	m      *aoi.SyncManager
+	seen   bool
+	pushed bool
+}
+
+func (c *reentrantCallback) OnEnter(watcherID aoi.PlayerID, targetID aoi.EntityID) {
+	c.seen = c.m.CanSee(watcherID, targetID)
+	if !c.pushed {
+		c.pushed = true
+		// 被看见就把目标推远
+		c.m.MoveEntity(targetID, aoitest.At(100))
+	}
+}
+
+func (c *reentrantCallback) OnLeave(aoi.PlayerID, aoi.EntityID) {}
+
+func TestSyncManagerReentrantCallback(t *testing.T) {
+	m := aoi.NewSyncManager(three_dim.NewManager())
+	cb := &reentrantCallback{m: m}
+	m.SetCallback(cb)
+	m.AddPlayer(1)
+	m.AddEntity(1, aoitest.At(0), 10)
+	m.Subscribe(1, 1)
+	m.AddEntity(2, aoitest.At(1), 0)
+	if !cb.seen {
+		t.Fatal("CanSee inside OnEnter should observe the new state")
+	}
+	if m.CanSee(1, 2) {
+		t.Fatal("write issued from inside a callback was not applied")
+	}
+}
+
+func TestSyncManagerConcurrentReads(t *testing.T) {
+	m := aoi.NewSyncManager(three_dim.NewManager())
+	m.SetCallback(aoitest.NewRecorder())
+	m.AddPlayer(1)
+	m.AddEntity(1, aoitest.At(0), 10)
+	m.Subscribe(1, 1)
+	for i := aoi.EntityID(2); i < 20; i++ {
+		m.AddEntity(i, aoitest.At(aoi.Float(i)), 0)
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					m.GetView(1)
+					m.CanSee(1, 5)
+				}
+			}
+		}()
+	}
+	for step := 0; step < 1000; step++ {
+		m.MoveEntity(1, aoitest.At(aoi.Float(step%30)))
+	}
+	close(done)
+	wg.Wait()
+}
+
+// panicCallback 第一次收到 Enter 时 panic
+type panicCallback struct {
+	panicked bool
+	enters   int
+}
+
+func (c *panicCallback) OnEnter(aoi.PlayerID, aoi.EntityID) {
+	if !c.panicked {
+		c.panicked = true
+		panic("callback failure")
+	}
+	c.enters++
+}
+
+func (c *panicCallback) OnLeave(aoi.PlayerID, aoi.EntityID) {}
+
+func TestSyncManagerPanic(t *testing.T) {
+	m := aoi.NewSyncManager(three_dim.NewManager())
+	cb := &panicCallback{}
+	m.SetCallback(cb)
+	mustPanic := func(fn func()) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic")
+			}
+		}()
+		fn()
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// 管理器调用中 panic 之后锁被释放
+		mustPanic(func() { m.Update(func(aoi.AOIManager) { panic("manager failure") }) })
+		m.AddPlayer(1)
+		m.AddEntity(1, aoitest.At(0), 10)
+		m.Subscribe(1, 1)
+		// 回调中 panic 之后，之后的事件仍然会被投递
+		mustPanic(func() { m.AddEntity(2, aoitest.At(1), 0) })
+		m.AddEntity(3, aoitest.At(2), 0)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("SyncManager deadlocked after a panic")
+	}
+	if cb.enters != 1 || !m.CanSee(1, 3) {
+		t.Fatalf("enters after the panic = %d, want 1", cb.enters)
+	}
+}
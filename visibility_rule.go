@@ -0,0 +1,58 @@
+package aoi
+
+import (
+	"math"
+)
+
+// Stealth 潜行与侦测属性
+type Stealth struct {
+	Level        int   // 潜行等级，0 表示不潜行
+	Detection    int   // 侦测等级
+	RevealRadius Float // 作为观察者时，这个距离之内无视目标的潜行等级
+}
+
+// RuleSubject 可见性规则看到的实体信息 (2D 管理器中 Pos 的 Y 总是 0)
+type RuleSubject struct {
+	ID      EntityID
+	Pos     Position
+	Stealth Stealth
+}
+
+// VisibilityRule 按实体对判定可见性的规则
+// 管理器在几何判定 (视野范围) 通过之后调用，几何关系本身 (例如 3D 的 ViewCounts) 不受规则影响
+type VisibilityRule interface {
+	// Visible watcher 能否看见 target
+	Visible(watcher, target RuleSubject) bool
+}
+
+// VisibilityRuleFunc 把普通函数当作 VisibilityRule 使用
+type VisibilityRuleFunc func(watcher, target RuleSubject) bool
+
+func (f VisibilityRuleFunc) Visible(watcher, target RuleSubject) bool {
+	return f(watcher, target)
+}
+
+// StealthRule 默认规则：目标的潜行等级不超过观察者的侦测等级，或者距离在观察者的揭示半径之内
+type StealthRule struct{}
+
+func (StealthRule) Visible(watcher, target RuleSubject) bool {
+	if target.Stealth.Level <= watcher.Stealth.Detection {
+		return true
+	}
+	return Distance(watcher.Pos, target.Pos) <= watcher.Stealth.RevealRadius
+}
+
+// Distance 两点之间的距离
+func Distance(a, b Position) Float {
+	dx, dy, dz := float64(a.X-b.X), float64(a.Y-b.Y), float64(a.Z-b.Z)
+	return Float(math.Sqrt(dx*dx + dy*dy + dz*dz))
+}
+
+// StealthManager 支持潜行/侦测与自定义可见性规则的管理器
+type StealthManager interface {
+	AOIManager
+	// SetStealth 设置实体的潜行/侦测属性
+	SetStealth(id EntityID, s Stealth)
+	// SetVisibilityRule 设置可见性规则，nil 表示使用 StealthRule
+	SetVisibilityRule(r VisibilityRule)
+}